@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// awsAccount holds the credentials used to scan one AWS account
+type awsAccount struct {
+	id  string
+	arn string // caller identity, recorded in the audit log
+	cfg aws.Config
+}
+
+// loadAWSAccounts builds a config for every named profile and every role to
+// assume. Roles are assumed with the default credentials. Without profiles
+// or roles, only the default credentials are used.
+func loadAWSAccounts(ctx context.Context, profiles, roleARNs []string) ([]awsAccount, error) {
+	var cfgs []aws.Config
+
+	for _, profile := range profiles {
+		cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profile))
+		if err != nil {
+			return nil, fmt.Errorf("unable to load SDK config for profile %s: %w", profile, err)
+		}
+		cfgs = append(cfgs, cfg)
+	}
+
+	if len(roleARNs) > 0 || len(profiles) == 0 {
+		base, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load SDK config: %w", err)
+		}
+
+		if len(roleARNs) == 0 {
+			cfgs = append(cfgs, base)
+		}
+
+		stsClient := sts.NewFromConfig(base)
+		for _, roleARN := range roleARNs {
+			cfg := base.Copy()
+			cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, roleARN))
+			cfgs = append(cfgs, cfg)
+		}
+	}
+
+	// Resolve the account ID behind each set of credentials concurrently;
+	// this also fails fast on credentials that don't work.
+	accounts := make([]awsAccount, len(cfgs))
+	errs := make([]error, len(cfgs))
+
+	var wg sync.WaitGroup
+	for i, cfg := range cfgs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to get caller identity: %w", err)
+				return
+			}
+			accounts[i] = awsAccount{
+				id:  aws.ToString(identity.Account),
+				arn: aws.ToString(identity.Arn),
+				cfg: cfg,
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Audited actions
+const (
+	auditReveal  = "reveal"
+	auditDiff    = "diff"
+	auditCopy    = "copy"
+	auditDelete  = "delete"
+	auditRestore = "restore"
+	auditCreate  = "create"
+	auditPut     = "put"
+	auditAttach  = "attach-stage"
+	auditDetach  = "detach-stage"
+	auditRotate  = "rotate"
+)
+
+// AuditEvent is one line of the audit log
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	Identity  string    `json:"identity,omitempty"`
+	Action    string    `json:"action"`
+	Secret    string    `json:"secret"`
+	ARN       string    `json:"arn,omitempty"`
+	VersionId string    `json:"version_id,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Stage     string    `json:"stage,omitempty"`
+	Account   string    `json:"account,omitempty"`
+	Region    string    `json:"region,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// AuditLog appends events as JSON lines to a local file. The file is only
+// ever appended to.
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewAuditLog(path string) *AuditLog {
+	if path == "" {
+		path = defaultAuditPath()
+	}
+	return &AuditLog{path: path}
+}
+
+func defaultAuditPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "sniffy-audit.jsonl"
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "sniffy", "audit.jsonl")
+}
+
+// identifiedStore is implemented by stores that know which principal they
+// act as
+type identifiedStore interface {
+	Identity(ctx context.Context) string
+}
+
+// newAuditEvent describes an action on a secret, attributed to the principal
+// of the store the secret lives in
+func newAuditEvent(ctx context.Context, action string, result SecretResult, versionId string, err error) AuditEvent {
+	event := AuditEvent{
+		Time:      time.Now().UTC(),
+		Action:    action,
+		Secret:    result.Name,
+		ARN:       result.ARN,
+		VersionId: versionId,
+		Account:   result.Account,
+		Region:    result.Region,
+	}
+	if identified, ok := result.store.(identifiedStore); ok {
+		event.Identity = identified.Identity(ctx)
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+func (a *AuditLog) Record(event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o700); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// runAudit prints the audit log, optionally filtered
+func runAudit(args []string) int {
+	var opts options
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	opts.registerConfig(fs)
+	action := fs.String("action", "", "Only show events for this action: reveal, diff, copy, delete, restore, create, put, attach-stage, detach-stage or rotate")
+	secret := fs.String("secret", "", "Only show events for secrets whose name or ARN contains this text")
+	identity := fs.String("identity", "", "Only show events by principals containing this text")
+	since := fs.String("since", "", "Only show events on or after this date (YYYY-MM-DD)")
+	format := fs.String("format", "table", "Output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sniffy audit [flags]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (want table or json)\n", *format)
+		return exitError
+	}
+
+	var sinceTime time.Time
+	if *since != "" {
+		t, err := time.ParseInLocation("2006-01-02", *since, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --since date %q\n", *since)
+			return exitError
+		}
+		sinceTime = t
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	path := NewAuditLog(cfg.AuditLog).path
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read audit log: %v\n", err)
+		return exitError
+	}
+	defer f.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping malformed line %d of %s\n", lineNo, path)
+			continue
+		}
+		if *action != "" && event.Action != *action {
+			continue
+		}
+		if *secret != "" && !strings.Contains(event.Secret, *secret) && !strings.Contains(event.ARN, *secret) {
+			continue
+		}
+		if *identity != "" && !strings.Contains(event.Identity, *identity) {
+			continue
+		}
+		if !sinceTime.IsZero() && event.Time.Before(sinceTime) {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read audit log: %v\n", err)
+		return exitError
+	}
+
+	if *format == "json" {
+		err = writeAuditJSON(os.Stdout, events)
+	} else {
+		err = writeAuditTable(os.Stdout, events)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write events: %v\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func writeAuditTable(w io.Writer, events []AuditEvent) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tIDENTITY\tACTION\tSECRET\tVERSION\tKEY\tSTAGE\tERROR")
+	for _, e := range events {
+		secret := e.ARN
+		if secret == "" {
+			secret = e.Secret
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Identity, e.Action, secret, e.VersionId, e.Key, e.Stage, e.Error)
+	}
+	return tw.Flush()
+}
+
+func writeAuditJSON(w io.Writer, events []AuditEvent) error {
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+)
+
+// CloudTrail allows two LookupEvents calls a second per account and region
+const (
+	cloudTrailInterval = 500 * time.Millisecond
+	cloudTrailPageSize = 50
+)
+
+// AccessEvidence is the latest GetSecretValue call CloudTrail recorded for a
+// secret
+type AccessEvidence struct {
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal"`
+	// Source is the caller's IP address, or the AWS service that made the
+	// call on its behalf
+	Source    string `json:"source"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// AccessTracer is implemented by stores that can tell who last read a secret
+type AccessTracer interface {
+	// LastRead finds the latest read of the secret since the given time,
+	// reading at most maxEvents of the secret's events. read is nil when
+	// there was none; truncated says the cap was hit before one was found.
+	LastRead(ctx context.Context, entry SecretEntry, since time.Time, maxEvents int) (read *AccessEvidence, truncated bool, err error)
+}
+
+// cloudTrail looks up the events of one account and region. Lookups for
+// every secret share its throttle.
+type cloudTrail struct {
+	client cloudtrail.LookupEventsAPIClient
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newCloudTrail(cfg aws.Config) *cloudTrail {
+	return &cloudTrail{client: cloudtrail.NewFromConfig(cfg)}
+}
+
+// wait blocks until the throttle lets another call through
+func (ct *cloudTrail) wait(ctx context.Context) error {
+	ct.mu.Lock()
+	at := ct.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	ct.next = at.Add(cloudTrailInterval)
+	ct.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cloudTrailRecord is the part of an event's CloudTrailEvent document that
+// says who read which secret
+type cloudTrailRecord struct {
+	EventTime    time.Time `json:"eventTime"`
+	UserIdentity struct {
+		ARN string `json:"arn"`
+	} `json:"userIdentity"`
+	SourceIPAddress   string `json:"sourceIPAddress"`
+	UserAgent         string `json:"userAgent"`
+	RequestParameters struct {
+		SecretID string `json:"secretId"`
+	} `json:"requestParameters"`
+}
+
+// readsSecret reports whether a GetSecretValue call with the given SecretId
+// read the secret. IDs can be names, ARNs or partial ARNs.
+func readsSecret(id string, entry SecretEntry) bool {
+	for _, key := range secretKeys(entry) {
+		if id == key {
+			return true
+		}
+	}
+	return false
+}
+
+// lastRead pages through the secret's events, newest first, until it finds
+// a GetSecretValue call
+func (ct *cloudTrail) lastRead(ctx context.Context, entry SecretEntry, since time.Time, maxEvents int) (*AccessEvidence, bool, error) {
+	resource := entry.ARN
+	if resource == "" {
+		resource = entry.Name
+	}
+	pages := cloudtrail.NewLookupEventsPaginator(ct.client, &cloudtrail.LookupEventsInput{
+		LookupAttributes: []types.LookupAttribute{{
+			AttributeKey:   types.LookupAttributeKeyResourceName,
+			AttributeValue: aws.String(resource),
+		}},
+		StartTime:  aws.Time(since),
+		MaxResults: aws.Int32(int32(min(cloudTrailPageSize, maxEvents))),
+	})
+
+	for seen := 0; pages.HasMorePages(); {
+		if seen >= maxEvents {
+			return nil, true, nil
+		}
+		if err := ct.wait(ctx); err != nil {
+			return nil, false, fmt.Errorf("failed to look up CloudTrail events: %w", err)
+		}
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to look up CloudTrail events: %w", err)
+		}
+
+		for _, event := range page.Events {
+			seen++
+			if aws.ToString(event.EventName) != "GetSecretValue" {
+				continue
+			}
+			var record cloudTrailRecord
+			if err := json.Unmarshal([]byte(aws.ToString(event.CloudTrailEvent)), &record); err != nil {
+				continue
+			}
+			if !readsSecret(record.RequestParameters.SecretID, entry) {
+				continue
+			}
+			principal := record.UserIdentity.ARN
+			if principal == "" {
+				principal = aws.ToString(event.Username)
+			}
+			return &AccessEvidence{
+				Time:      record.EventTime,
+				Principal: principal,
+				Source:    record.SourceIPAddress,
+				UserAgent: record.UserAgent,
+			}, false, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// traceRead looks up who last read a result. A lookup that failed or gave
+// up is reported as an info finding rather than failing the scan.
+func (sa *SecretAnalyzer) traceRead(ctx context.Context, tracer AccessTracer, result *SecretResult) {
+	since := time.Now().AddDate(0, 0, -sa.config.CloudTrail.Days)
+	entry := SecretEntry{Name: result.Name, ARN: result.ARN}
+	read, truncated, err := tracer.LastRead(ctx, entry, since, sa.config.CloudTrail.MaxEvents)
+	switch {
+	case err != nil:
+		result.Findings = append(result.Findings, Finding{
+			Rule:     ruleCloudTrailFailed,
+			Severity: SeverityInfo,
+			Message:  err.Error(),
+		})
+	case truncated:
+		result.Findings = append(result.Findings, Finding{
+			Rule:     ruleCloudTrailTruncated,
+			Severity: SeverityInfo,
+			Message:  fmt.Sprintf("no read among the latest %d CloudTrail events", sa.config.CloudTrail.MaxEvents),
+		})
+	}
+	result.LastRead = read
+}
+
+// mergeTraced copies a traced result's last read and findings to the same
+// secret in results
+func mergeTraced(results []SecretResult, traced SecretResult) {
+	key := resultKey(traced)
+	for i := range results {
+		if resultKey(results[i]) == key {
+			results[i].LastRead = traced.LastRead
+			results[i].Findings = traced.Findings
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/smithy-go"
+)
+
+const testSecretARN = "arn:aws:secretsmanager:us-east-1:111111111111:secret:"
+
+type testEvent struct {
+	name      string
+	secretID  string
+	principal string
+}
+
+// fakeLookupEvents serves events one per page, newest first, for the
+// resource it is asked about
+type fakeLookupEvents struct {
+	t        *testing.T
+	resource string
+	events   []testEvent
+	err      error
+}
+
+func (f *fakeLookupEvents) LookupEvents(ctx context.Context, params *cloudtrail.LookupEventsInput, optFns ...func(*cloudtrail.Options)) (*cloudtrail.LookupEventsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	attrs := params.LookupAttributes
+	if len(attrs) != 1 || attrs[0].AttributeKey != types.LookupAttributeKeyResourceName || aws.ToString(attrs[0].AttributeValue) != f.resource {
+		f.t.Errorf("LookupAttributes = %+v, want ResourceName %s", attrs, f.resource)
+	}
+
+	page, _ := strconv.Atoi(aws.ToString(params.NextToken))
+	out := &cloudtrail.LookupEventsOutput{}
+	if page < len(f.events) {
+		e := f.events[page]
+		record := fmt.Sprintf(`{"eventTime":"2026-10-01T12:00:%02dZ","userIdentity":{"arn":%q},"sourceIPAddress":"10.0.0.1","requestParameters":{"secretId":%q}}`, len(f.events)-page, e.principal, e.secretID)
+		out.Events = []types.Event{{EventName: aws.String(e.name), Username: aws.String("user"), CloudTrailEvent: aws.String(record)}}
+		if page+1 < len(f.events) {
+			out.NextToken = aws.String(strconv.Itoa(page + 1))
+		}
+	}
+	return out, nil
+}
+
+func TestCloudTrailLastRead(t *testing.T) {
+	read := func(secretID, principal string) testEvent {
+		return testEvent{name: "GetSecretValue", secretID: secretID, principal: principal}
+	}
+	described := testEvent{name: "DescribeSecret", secretID: "prod/db", principal: "auditor"}
+
+	tests := []struct {
+		name          string
+		entry         SecretEntry
+		events        []testEvent
+		maxEvents     int
+		wantPrincipal string
+		wantTruncated bool
+	}{
+		{
+			name:          "by name",
+			entry:         SecretEntry{Name: "prod/db", ARN: testSecretARN + "prod/db-a1B2c3"},
+			events:        []testEvent{read("prod/db", "app")},
+			maxEvents:     10,
+			wantPrincipal: "app",
+		},
+		{
+			name:          "by ARN",
+			entry:         SecretEntry{Name: "prod/db", ARN: testSecretARN + "prod/db-a1B2c3"},
+			events:        []testEvent{read(testSecretARN+"prod/db-a1B2c3", "app")},
+			maxEvents:     10,
+			wantPrincipal: "app",
+		},
+		{
+			name:          "by partial ARN",
+			entry:         SecretEntry{Name: "prod/db", ARN: testSecretARN + "prod/db-a1B2c3"},
+			events:        []testEvent{read(testSecretARN+"prod/db", "app")},
+			maxEvents:     10,
+			wantPrincipal: "app",
+		},
+		{
+			name:   "partial ARN of another secret",
+			entry:  SecretEntry{Name: "prod/db-old", ARN: testSecretARN + "prod/db-old-d4E5f6"},
+			events: []testEvent{read(testSecretARN+"prod/db", "app")},
+			// A read of prod/db is not a read of prod/db-old
+			maxEvents: 10,
+		},
+		{
+			name:          "later page",
+			entry:         SecretEntry{Name: "prod/db", ARN: testSecretARN + "prod/db-a1B2c3"},
+			events:        []testEvent{described, read("prod/db", "app"), read("prod/db", "older")},
+			maxEvents:     10,
+			wantPrincipal: "app",
+		},
+		{
+			name:          "cap reached",
+			entry:         SecretEntry{Name: "prod/db", ARN: testSecretARN + "prod/db-a1B2c3"},
+			events:        []testEvent{described, read("prod/db", "app")},
+			maxEvents:     1,
+			wantTruncated: true,
+		},
+		{
+			name:      "no reads",
+			entry:     SecretEntry{Name: "prod/db", ARN: testSecretARN + "prod/db-a1B2c3"},
+			events:    []testEvent{described},
+			maxEvents: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ct := &cloudTrail{client: &fakeLookupEvents{t: t, resource: tt.entry.ARN, events: tt.events}}
+			got, truncated, err := ct.lastRead(context.Background(), tt.entry, time.Now().AddDate(0, 0, -90), tt.maxEvents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if truncated != tt.wantTruncated {
+				t.Errorf("truncated = %t, want %t", truncated, tt.wantTruncated)
+			}
+			switch {
+			case tt.wantPrincipal == "" && got != nil:
+				t.Errorf("read = %+v, want none", got)
+			case tt.wantPrincipal != "" && (got == nil || got.Principal != tt.wantPrincipal):
+				t.Errorf("read = %+v, want principal %s", got, tt.wantPrincipal)
+			}
+		})
+	}
+}
+
+func TestCloudTrailError(t *testing.T) {
+	ct := &cloudTrail{client: &fakeLookupEvents{err: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}}}
+	_, _, err := ct.lastRead(context.Background(), SecretEntry{Name: "prod/db"}, time.Now(), 10)
+	if want := "failed to look up CloudTrail events: api error AccessDeniedException: not authorized"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestCloudTrailThrottle(t *testing.T) {
+	ct := &cloudTrail{}
+	start := time.Now()
+	for range 3 {
+		if err := ct.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first call goes straight through
+	if elapsed := time.Since(start); elapsed < 2*cloudTrailInterval {
+		t.Errorf("three calls took %s, want at least %s", elapsed, 2*cloudTrailInterval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ct.wait(ctx); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
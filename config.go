@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultThresholdDays         = 14
+	defaultClipboardClearSeconds = 30
+	defaultMinSeverity           = SeverityMedium
+	defaultOwnerTag              = "owner"
+	defaultMaxDeprecatedVersions = 5
+	defaultCloudTrailDays        = 90
+	defaultCloudTrailMaxEvents   = 500
+)
+
+// Config is the user configuration, read from ~/.config/sniffy/config.yaml
+// unless --config points elsewhere.
+type Config struct {
+	// Threshold is the number of days without access after which a secret
+	// is considered potentially unused
+	Threshold int             `yaml:"threshold"`
+	Rules     []ThresholdRule `yaml:"rules"`
+	Exclude   ExcludeConfig   `yaml:"exclude"`
+
+	// RecoveryWindowDays is how long deleted secrets can be restored, from
+	// 7 to 30 days; zero keeps the store's default
+	RecoveryWindowDays int `yaml:"recovery_window_days"`
+
+	// AuditLog is the file reveals, copies, writes and deletes are recorded in
+	// (default ~/.local/state/sniffy/audit.jsonl)
+	AuditLog string `yaml:"audit_log"`
+
+	// ClipboardClearSeconds is how long a copied secret value stays on the
+	// clipboard; zero leaves it there
+	ClipboardClearSeconds int `yaml:"clipboard_clear_seconds"`
+
+	Findings FindingsConfig `yaml:"findings"`
+
+	CloudTrail CloudTrailConfig `yaml:"cloudtrail"`
+
+	// References are local directories searched for files that reference
+	// secrets, such as Terraform, Helm charts and application code
+	References []string `yaml:"references"`
+
+	Kubernetes KubernetesConfig `yaml:"kubernetes"`
+}
+
+// KubernetesConfig says where to find the ExternalSecret, SecretStore and
+// SecretProviderClass objects that pull secrets into clusters
+type KubernetesConfig struct {
+	// Manifests are directories of YAML or JSON manifests
+	Manifests []string `yaml:"manifests"`
+	// Contexts are kubeconfig contexts to read the objects from with kubectl
+	Contexts []string `yaml:"contexts"`
+}
+
+// Enabled reports whether any manifests or clusters are to be read
+func (k KubernetesConfig) Enabled() bool {
+	return len(k.Manifests) > 0 || len(k.Contexts) > 0
+}
+
+// CloudTrailConfig controls looking up who last read each secret in
+// CloudTrail
+type CloudTrailConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Days is how far back to look, at most the 90 days CloudTrail keeps
+	Days int `yaml:"days"`
+	// MaxEvents caps the events read per secret while looking for its
+	// latest GetSecretValue call
+	MaxEvents int `yaml:"max_events"`
+}
+
+// FindingsConfig tunes the rules secrets are checked against
+type FindingsConfig struct {
+	// MinSeverity is the lowest severity that puts a secret in the default
+	// view and fails a headless scan
+	MinSeverity string `yaml:"min_severity"`
+	// OwnerTag is the tag that names a secret's owner
+	OwnerTag              string `yaml:"owner_tag"`
+	MaxDeprecatedVersions int    `yaml:"max_deprecated_versions"`
+	// Deep runs the rules that need an API call per secret
+	Deep bool `yaml:"deep"`
+	// Disable lists rule IDs to skip
+	Disable []string `yaml:"disable"`
+
+	minSeverity Severity
+}
+
+// ThresholdRule overrides the threshold for secrets whose name matches a
+// glob pattern. "*" matches any run of characters, including "/".
+type ThresholdRule struct {
+	Pattern   string `yaml:"pattern"`
+	Threshold int    `yaml:"threshold"`
+
+	re *regexp.Regexp
+}
+
+// ExcludeConfig lists secrets to leave out of the analysis. Names matching
+// Allow are never excluded.
+type ExcludeConfig struct {
+	Patterns []string   `yaml:"patterns"`
+	Regex    []string   `yaml:"regex"`
+	Tags     []TagMatch `yaml:"tags"`
+	Allow    []string   `yaml:"allow"`
+
+	patterns []*regexp.Regexp
+	regex    []*regexp.Regexp
+	allow    []*regexp.Regexp
+}
+
+// TagMatch matches a tag by key, and by value unless Value is empty
+type TagMatch struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "sniffy", "config.yaml")
+}
+
+// LoadConfig reads the config file at path. An empty path means the default
+// location, which may be missing; an explicit path must exist.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{
+		Threshold:             defaultThresholdDays,
+		ClipboardClearSeconds: defaultClipboardClearSeconds,
+		Findings: FindingsConfig{
+			MinSeverity:           defaultMinSeverity.String(),
+			OwnerTag:              defaultOwnerTag,
+			MaxDeprecatedVersions: defaultMaxDeprecatedVersions,
+			minSeverity:           defaultMinSeverity,
+		},
+		CloudTrail: CloudTrailConfig{
+			Days:      defaultCloudTrailDays,
+			MaxEvents: defaultCloudTrailMaxEvents,
+		},
+	}
+
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+		if path == "" {
+			return cfg, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	if cfg.Threshold < 0 {
+		return nil, fmt.Errorf("invalid config %s: threshold must not be negative", path)
+	}
+	if err := validateRecoveryWindow(cfg.RecoveryWindowDays); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if cfg.ClipboardClearSeconds < 0 {
+		return nil, fmt.Errorf("invalid config %s: clipboard_clear_seconds must not be negative", path)
+	}
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("invalid config %s: rule %d has no pattern", path, i+1)
+		}
+		if rule.Threshold < 0 {
+			return nil, fmt.Errorf("invalid config %s: rule %q threshold must not be negative", path, rule.Pattern)
+		}
+		rule.re = globToRegexp(rule.Pattern)
+	}
+
+	if err := cfg.Exclude.compile(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	if err := cfg.Findings.compile(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	if cfg.CloudTrail.Days < 1 || cfg.CloudTrail.Days > defaultCloudTrailDays {
+		return nil, fmt.Errorf("invalid config %s: cloudtrail days must be between 1 and %d", path, defaultCloudTrailDays)
+	}
+	if cfg.CloudTrail.MaxEvents < 1 {
+		return nil, fmt.Errorf("invalid config %s: cloudtrail max_events must be positive", path)
+	}
+
+	return cfg, nil
+}
+
+func (e *ExcludeConfig) compile() error {
+	for _, pattern := range e.Patterns {
+		e.patterns = append(e.patterns, globToRegexp(pattern))
+	}
+	for _, expr := range e.Regex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("exclude regex %q: %w", expr, err)
+		}
+		e.regex = append(e.regex, re)
+	}
+	for _, tag := range e.Tags {
+		if tag.Key == "" {
+			return fmt.Errorf("exclude tag has no key")
+		}
+	}
+	for _, pattern := range e.Allow {
+		e.allow = append(e.allow, globToRegexp(pattern))
+	}
+	return nil
+}
+
+func (f *FindingsConfig) compile() error {
+	severity, err := parseSeverity(f.MinSeverity)
+	if err != nil {
+		return fmt.Errorf("findings min_severity: %w", err)
+	}
+	f.minSeverity = severity
+	if f.OwnerTag == "" {
+		return fmt.Errorf("findings owner_tag must not be empty")
+	}
+	if f.MaxDeprecatedVersions < 0 {
+		return fmt.Errorf("findings max_deprecated_versions must not be negative")
+	}
+	for _, id := range f.Disable {
+		known := false
+		for _, rule := range findingRules {
+			known = known || rule.id == id
+		}
+		if !known {
+			return fmt.Errorf("findings disable: unknown rule %q", id)
+		}
+	}
+	return nil
+}
+
+// Match reports whether a secret is excluded and explains why
+func (e *ExcludeConfig) Match(entry SecretEntry) (string, bool) {
+	for _, re := range e.allow {
+		if re.MatchString(entry.Name) {
+			return "", false
+		}
+	}
+
+	for i, re := range e.patterns {
+		if re.MatchString(entry.Name) {
+			return fmt.Sprintf("name matches pattern %q", e.Patterns[i]), true
+		}
+	}
+	for i, re := range e.regex {
+		if re.MatchString(entry.Name) {
+			return fmt.Sprintf("name matches regex %q", e.Regex[i]), true
+		}
+	}
+	for _, tag := range e.Tags {
+		value, ok := entry.Tags[tag.Key]
+		if !ok {
+			continue
+		}
+		if tag.Value == "" {
+			return fmt.Sprintf("has tag %q", tag.Key), true
+		}
+		if value == tag.Value {
+			return fmt.Sprintf("has tag %s=%s", tag.Key, tag.Value), true
+		}
+	}
+
+	return "", false
+}
+
+func validateRecoveryWindow(days int) error {
+	if days != 0 && (days < 7 || days > 30) {
+		return fmt.Errorf("recovery window must be between 7 and 30 days")
+	}
+	return nil
+}
+
+// ThresholdFor returns the staleness threshold in days for a secret name.
+// The first matching rule wins.
+func (c *Config) ThresholdFor(name string) int {
+	for _, rule := range c.Rules {
+		if rule.re.MatchString(name) {
+			return rule.Threshold
+		}
+	}
+	return c.Threshold
+}
+
+// globToRegexp compiles a glob where "*" matches any run of characters and
+// "?" matches a single character into an anchored regular expression.
+func globToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"prod/*", "prod/db", true},
+		{"prod/*", "prod/", true},
+		{"prod/*", "staging/db", false},
+		{"prod/*", "x/prod/db", false},
+		{"db-?", "db-1", true},
+		{"db-?", "db-10", false},
+		{"app.config", "app.config", true},
+		{"app.config", "app-config", false},
+		{"*+*", "a+b", true},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := globToRegexp(tt.pattern).MatchString(tt.name); got != tt.want {
+				t.Errorf("globToRegexp(%q) matches %q = %t, want %t", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeMatch(t *testing.T) {
+	exclude := ExcludeConfig{
+		Patterns: []string{"aws/*"},
+		Regex:    []string{`^rds!`},
+		Tags: []TagMatch{
+			{Key: "sniffy-ignore"},
+			{Key: "env", Value: "sandbox"},
+		},
+		Allow: []string{"aws/keep-*"},
+	}
+	if err := exclude.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		entry      SecretEntry
+		wantReason string
+	}{
+		{"pattern", SecretEntry{Name: "aws/db"}, `name matches pattern "aws/*"`},
+		{"regex", SecretEntry{Name: "rds!cluster-1"}, `name matches regex "^rds!"`},
+		{"tag with any value", SecretEntry{Name: "app", Tags: map[string]string{"sniffy-ignore": ""}}, `has tag "sniffy-ignore"`},
+		{"tag value", SecretEntry{Name: "app", Tags: map[string]string{"env": "sandbox"}}, "has tag env=sandbox"},
+		{"other tag value", SecretEntry{Name: "app", Tags: map[string]string{"env": "prod"}}, ""},
+		{"no match", SecretEntry{Name: "prod/db"}, ""},
+		{"allow beats pattern", SecretEntry{Name: "aws/keep-me"}, ""},
+		{"allow beats tag", SecretEntry{Name: "aws/keep-me", Tags: map[string]string{"sniffy-ignore": "true"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, excluded := exclude.Match(tt.entry)
+			if excluded != (tt.wantReason != "") || reason != tt.wantReason {
+				t.Errorf("Match(%s) = %q, %t, want %q", tt.entry.Name, reason, excluded, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestExcludeCompile(t *testing.T) {
+	if err := (&ExcludeConfig{Regex: []string{"("}}).compile(); err == nil {
+		t.Error("want an error for an invalid regex")
+	}
+	if err := (&ExcludeConfig{Tags: []TagMatch{{Value: "x"}}}).compile(); err == nil {
+		t.Error("want an error for a tag without a key")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// Bulk deletes run on a small worker pool and share one rate limit, so a
+// large selection doesn't trip the store's API throttling.
+const (
+	deleteWorkers     = 4
+	deleteInterval    = 100 * time.Millisecond // at most 10 calls a second
+	deleteMaxAttempts = 5
+)
+
+// deleteBaseBackoff is the wait after the first throttled attempt; tests
+// shorten it
+var deleteBaseBackoff = 500 * time.Millisecond
+
+// deleteOutcome is the result of deleting one secret. index is the
+// secret's position in the slice passed to deleteSecrets.
+type deleteOutcome struct {
+	index    int
+	result   SecretResult
+	attempts int
+	err      error
+}
+
+// deleteSecrets deletes secrets concurrently and sends one outcome per
+// secret, in completion order. The channel is closed when all are done.
+// Every delete is recorded in the audit log; a failure to record it is
+// reported as the outcome's error.
+func deleteSecrets(ctx context.Context, secrets []SecretResult, opts DeleteOptions, audit *AuditLog) <-chan deleteOutcome {
+	jobs := make(chan int)
+	outcomes := make(chan deleteOutcome)
+
+	limiter := time.NewTicker(deleteInterval)
+
+	var wg sync.WaitGroup
+	for range min(deleteWorkers, len(secrets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outcome := deleteWithRetry(ctx, limiter.C, secrets[i], opts)
+				outcome.index = i
+				if auditErr := audit.Record(newAuditEvent(ctx, auditDelete, secrets[i], "", outcome.err)); auditErr != nil {
+					outcome.err = errors.Join(outcome.err, auditErr)
+				}
+				outcomes <- outcome
+			}
+		}()
+	}
+
+	go func() {
+		for i := range secrets {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	go func() {
+		wg.Wait()
+		limiter.Stop()
+		close(outcomes)
+	}()
+
+	return outcomes
+}
+
+// deleteWithRetry deletes one secret, backing off exponentially with jitter
+// while the store throttles
+func deleteWithRetry(ctx context.Context, limiter <-chan time.Time, result SecretResult, opts DeleteOptions) deleteOutcome {
+	outcome := deleteOutcome{result: result}
+	for {
+		select {
+		case <-limiter:
+		case <-ctx.Done():
+			outcome.err = ctx.Err()
+			return outcome
+		}
+
+		outcome.attempts++
+		outcome.err = result.store.DeleteSecret(ctx, secretID(result), opts)
+		if outcome.err == nil || !isThrottled(outcome.err) || outcome.attempts == deleteMaxAttempts {
+			return outcome
+		}
+
+		backoff := deleteBaseBackoff << (outcome.attempts - 1)
+		backoff += rand.N(backoff / 2)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			outcome.err = ctx.Err()
+			return outcome
+		}
+	}
+}
+
+// arnAddressedStore is implemented by stores that accept a secret's ARN
+// wherever they accept its name
+type arnAddressedStore interface {
+	AcceptsARN()
+}
+
+// secretID is the ID a secret is deleted by. The ARN pins the exact secret
+// that was listed or reviewed; a name could since belong to a new secret.
+func secretID(result SecretResult) string {
+	if _, ok := result.store.(arnAddressedStore); ok && result.ARN != "" {
+		return result.ARN
+	}
+	return result.Name
+}
+
+// isThrottled reports whether an error means the store is rate limiting us
+func isThrottled(err error) bool {
+	if errors.Is(err, errVaultThrottled) {
+		return true
+	}
+	return retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+}
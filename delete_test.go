@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// flakyStore fails each secret's first deletes with the errors queued for it
+type flakyStore struct {
+	mu       sync.Mutex
+	errs     map[string][]error
+	attempts map[string]int
+}
+
+func (s *flakyStore) ListSecretPages(ctx context.Context, fn func(page []SecretEntry) error) error {
+	return nil
+}
+
+func (s *flakyStore) ListSecretVersions(ctx context.Context, secretName string) ([]SecretVersion, error) {
+	return nil, nil
+}
+
+func (s *flakyStore) GetSecretValue(ctx context.Context, secretName, versionId string) (SecretValue, error) {
+	return SecretValue{}, nil
+}
+
+func (s *flakyStore) DeleteSecret(ctx context.Context, secretName string, opts DeleteOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[secretName]++
+	if errs := s.errs[secretName]; len(errs) > 0 {
+		s.errs[secretName] = errs[1:]
+		return errs[0]
+	}
+	return nil
+}
+
+func throttled(n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	}
+	return errs
+}
+
+func shortenBackoff(t *testing.T) {
+	backoff := deleteBaseBackoff
+	deleteBaseBackoff = time.Millisecond
+	t.Cleanup(func() { deleteBaseBackoff = backoff })
+}
+
+func TestDeleteWithRetry(t *testing.T) {
+	shortenBackoff(t)
+	denied := errors.New("AccessDeniedException: not authorized")
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"first try", nil, 1, false},
+		{"throttled then deleted", throttled(2), 3, false},
+		{"vault rate limit", []error{fmt.Errorf("vault returned 429: %w", errVaultThrottled)}, 2, false},
+		{"gives up", throttled(deleteMaxAttempts), deleteMaxAttempts, true},
+		{"not retried", []error{denied}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &flakyStore{errs: map[string][]error{"db": tt.errs}, attempts: map[string]int{}}
+			limiter := make(chan time.Time)
+			close(limiter)
+
+			outcome := deleteWithRetry(context.Background(), limiter, SecretResult{Name: "db", store: store}, DeleteOptions{})
+			if outcome.attempts != tt.wantAttempts || store.attempts["db"] != tt.wantAttempts {
+				t.Errorf("attempts = %d (store saw %d), want %d", outcome.attempts, store.attempts["db"], tt.wantAttempts)
+			}
+			if (outcome.err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %t", outcome.err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeleteWithRetryCanceled(t *testing.T) {
+	store := &flakyStore{errs: map[string][]error{"db": throttled(1)}, attempts: map[string]int{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	limiter := make(chan time.Time, 1)
+	limiter <- time.Now()
+
+	// The backoff after the throttled attempt is cut short
+	time.AfterFunc(10*time.Millisecond, cancel)
+	outcome := deleteWithRetry(ctx, limiter, SecretResult{Name: "db", store: store}, DeleteOptions{})
+	if !errors.Is(outcome.err, context.Canceled) || outcome.attempts != 1 {
+		t.Errorf("outcome = %d attempts, %v, want 1 attempt, canceled", outcome.attempts, outcome.err)
+	}
+}
+
+func TestDeleteSecretsPartialFailure(t *testing.T) {
+	shortenBackoff(t)
+	store := &flakyStore{
+		errs: map[string][]error{
+			"b": {errors.New("ResourceNotFoundException: not found")},
+			"c": throttled(1),
+		},
+		attempts: map[string]int{},
+	}
+	var secrets []SecretResult
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		secrets = append(secrets, SecretResult{Name: name, store: store})
+	}
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	failed := map[string]bool{}
+	seen := map[int]bool{}
+	for outcome := range deleteSecrets(context.Background(), secrets, DeleteOptions{}, NewAuditLog(auditPath)) {
+		if seen[outcome.index] || secrets[outcome.index].Name != outcome.result.Name {
+			t.Errorf("outcome for %s has index %d", outcome.result.Name, outcome.index)
+		}
+		seen[outcome.index] = true
+		if outcome.err != nil {
+			failed[outcome.result.Name] = true
+		}
+	}
+
+	if len(seen) != len(secrets) {
+		t.Errorf("got %d outcomes, want %d", len(seen), len(secrets))
+	}
+	if len(failed) != 1 || !failed["b"] {
+		t.Errorf("failed = %v, want only b", failed)
+	}
+	if store.attempts["c"] != 2 {
+		t.Errorf("c deleted in %d attempts, want 2", store.attempts["c"])
+	}
+
+	// Failed deletes are audited too
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != len(secrets) {
+		t.Errorf("audit log has %d events, want %d", lines, len(secrets))
+	}
+}
+
+func TestSecretID(t *testing.T) {
+	arn := "arn:aws:secretsmanager:us-east-1:111111111111:secret:prod/db-a1B2c3"
+	tests := []struct {
+		name   string
+		result SecretResult
+		want   string
+	}{
+		{"Secrets Manager", SecretResult{Name: "prod/db", ARN: arn, store: &AWSSecretsManager{}}, arn},
+		{"Secrets Manager without ARN", SecretResult{Name: "prod/db", store: &AWSSecretsManager{}}, "prod/db"},
+		{"Parameter Store", SecretResult{Name: "/prod/db", ARN: "arn:aws:ssm:us-east-1:111111111111:parameter/prod/db", store: &AWSParameterStore{}}, "/prod/db"},
+		{"Vault", SecretResult{Name: "app/db", store: &VaultKVStore{}}, "app/db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := secretID(tt.result); got != tt.want {
+				t.Errorf("secretID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeletesPermanently(t *testing.T) {
+	tests := []struct {
+		name  string
+		store SecretStore
+		want  bool
+	}{
+		{"Secrets Manager", &AWSSecretsManager{}, false},
+		{"Parameter Store", &AWSParameterStore{}, true},
+		{"Vault", &VaultKVStore{}, false},
+		{"Vault with --vault-destroy", &VaultKVStore{destroy: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deletesPermanently(tt.store); got != tt.want {
+				t.Errorf("deletesPermanently() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type diffOp int
+
+const (
+	diffSame diffOp = iota
+	diffAdded
+	diffRemoved
+	diffChanged
+)
+
+// diffLine is one line of a version diff. For JSON objects Key is the
+// object key; for plain strings it is empty and OldLine and NewLine are the
+// line's numbers in each version, zero in a version without the line.
+type diffLine struct {
+	Op      diffOp
+	Key     string
+	OldLine int
+	NewLine int
+	Old     string
+	New     string
+}
+
+// maxLineDiffCells bounds the work of a plain-text line diff; larger values
+// are reported as replaced wholesale
+const maxLineDiffCells = 1_000_000
+
+// diffSecretValues compares two versions of a secret. JSON objects are
+// compared key by key, anything else line by line.
+func diffSecretValues(oldValue, newValue string) (lines []diffLine, isJSON bool) {
+	var oldObj, newObj map[string]any
+	if json.Unmarshal([]byte(oldValue), &oldObj) == nil && json.Unmarshal([]byte(newValue), &newObj) == nil &&
+		oldObj != nil && newObj != nil {
+		return diffJSON(oldObj, newObj), true
+	}
+	return diffText(oldValue, newValue), false
+}
+
+func diffJSON(oldObj, newObj map[string]any) []diffLine {
+	keys := make([]string, 0, len(oldObj)+len(newObj))
+	for key := range oldObj {
+		keys = append(keys, key)
+	}
+	for key := range newObj {
+		if _, ok := oldObj[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var lines []diffLine
+	for _, key := range keys {
+		oldVal, inOld := oldObj[key]
+		newVal, inNew := newObj[key]
+		line := diffLine{Key: key, Old: jsonValueString(oldVal), New: jsonValueString(newVal)}
+		switch {
+		case !inOld:
+			line.Op = diffAdded
+			line.Old = ""
+		case !inNew:
+			line.Op = diffRemoved
+			line.New = ""
+		case line.Old != line.New:
+			line.Op = diffChanged
+		default:
+			line.Op = diffSame
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// jsonValueString renders a JSON value for display; strings are shown
+// without quotes
+func jsonValueString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// diffText is a line diff based on the longest common subsequence
+func diffText(oldValue, newValue string) []diffLine {
+	a := strings.Split(oldValue, "\n")
+	b := strings.Split(newValue, "\n")
+
+	if len(a)*len(b) > maxLineDiffCells {
+		var lines []diffLine
+		for i, line := range a {
+			lines = append(lines, diffLine{Op: diffRemoved, OldLine: i + 1, Old: line})
+		}
+		for i, line := range b {
+			lines = append(lines, diffLine{Op: diffAdded, NewLine: i + 1, New: line})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// On ties removals go first, so a replaced line reads old then new
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{Op: diffSame, OldLine: i + 1, NewLine: j + 1, Old: a[i], New: b[j]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{Op: diffRemoved, OldLine: i + 1, Old: a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{Op: diffAdded, NewLine: j + 1, New: b[j]})
+			j++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffText(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []diffLine
+	}{
+		{
+			name: "same",
+			old:  "a\nb",
+			new:  "a\nb",
+			want: []diffLine{
+				{Op: diffSame, OldLine: 1, NewLine: 1, Old: "a", New: "a"},
+				{Op: diffSame, OldLine: 2, NewLine: 2, Old: "b", New: "b"},
+			},
+		},
+		{
+			name: "line added",
+			old:  "a\nc",
+			new:  "a\nb\nc",
+			want: []diffLine{
+				{Op: diffSame, OldLine: 1, NewLine: 1, Old: "a", New: "a"},
+				{Op: diffAdded, NewLine: 2, New: "b"},
+				{Op: diffSame, OldLine: 2, NewLine: 3, Old: "c", New: "c"},
+			},
+		},
+		{
+			name: "line removed",
+			old:  "a\nb\nc",
+			new:  "a\nc",
+			want: []diffLine{
+				{Op: diffSame, OldLine: 1, NewLine: 1, Old: "a", New: "a"},
+				{Op: diffRemoved, OldLine: 2, Old: "b"},
+				{Op: diffSame, OldLine: 3, NewLine: 2, Old: "c", New: "c"},
+			},
+		},
+		{
+			name: "line replaced",
+			old:  "user=app\npassword=old",
+			new:  "user=app\npassword=new",
+			want: []diffLine{
+				{Op: diffSame, OldLine: 1, NewLine: 1, Old: "user=app", New: "user=app"},
+				{Op: diffRemoved, OldLine: 2, Old: "password=old"},
+				{Op: diffAdded, NewLine: 2, New: "password=new"},
+			},
+		},
+		{
+			name: "lines replaced",
+			old:  "a\nx\ny\nb",
+			new:  "a\nz\nb",
+			want: []diffLine{
+				{Op: diffSame, OldLine: 1, NewLine: 1, Old: "a", New: "a"},
+				{Op: diffRemoved, OldLine: 2, Old: "x"},
+				{Op: diffRemoved, OldLine: 3, Old: "y"},
+				{Op: diffAdded, NewLine: 2, New: "z"},
+				{Op: diffSame, OldLine: 4, NewLine: 3, Old: "b", New: "b"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffText(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffText() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// secretFilter is a parsed filter query. Whitespace-separated terms must all
+// match: "key=value" requires a tag, "!key=value" forbids it, a value of "*"
+// matches any value, and any other term is fuzzy matched against the name.
+type secretFilter struct {
+	terms []string
+	tags  []tagFilter
+}
+
+type tagFilter struct {
+	key    string
+	value  string
+	negate bool
+}
+
+func parseFilter(query string) secretFilter {
+	var f secretFilter
+	for _, term := range strings.Fields(query) {
+		key, value, isTag := strings.Cut(term, "=")
+		if !isTag {
+			f.terms = append(f.terms, term)
+			continue
+		}
+		negate := strings.HasPrefix(key, "!")
+		f.tags = append(f.tags, tagFilter{
+			key:    strings.TrimPrefix(key, "!"),
+			value:  value,
+			negate: negate,
+		})
+	}
+	return f
+}
+
+func (f secretFilter) Match(result SecretResult) bool {
+	for _, term := range f.terms {
+		if !isFuzzyMatch(term, result.Name) {
+			return false
+		}
+	}
+	for _, tag := range f.tags {
+		value, ok := result.Tags[tag.key]
+		has := ok && (tag.value == "*" || tag.value == value)
+		if has == tag.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// appliedFilter is a filter the user applied, keeping the secrets that match
+// it or, for an exclude filter, the ones that don't
+type appliedFilter struct {
+	filter  secretFilter
+	include bool
+}
+
+func (f appliedFilter) keep(result SecretResult) bool {
+	return f.filter.Match(result) == f.include
+}
+
+// formatTags renders tags as "key=value" pairs sorted by key
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestParseFilter(t *testing.T) {
+	result := SecretResult{
+		Name: "prod/payments/db",
+		Tags: map[string]string{"owner": "payments", "env": "prod"},
+	}
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"paydb", true},
+		{"staging", false},
+		{"owner=payments", true},
+		{"owner=platform", false},
+		{"owner=*", true},
+		{"team=*", false},
+		{"!env=prod", false},
+		{"!env=staging", true},
+		{"!team=*", true},
+		{"prod owner=payments !env=staging", true},
+		{"prod owner=payments !env=prod", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := parseFilter(tt.query).Match(result); got != tt.want {
+				t.Errorf("parseFilter(%q).Match() = %t, want %t", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severity ranks findings from informational to critical
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText writes severities by name in JSON output
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func parseSeverity(name string) (Severity, error) {
+	i := slices.Index(severityNames, strings.ToLower(name))
+	if i < 0 {
+		return 0, fmt.Errorf("unknown severity %q (want %s)", name, strings.Join(severityNames, ", "))
+	}
+	return Severity(i), nil
+}
+
+// Finding is one problem a rule found with a secret
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Rule IDs, as used in findings and in the config's findings.disable list
+const (
+	ruleUnused              = "unused"
+	ruleNeverAccessed       = "never-accessed"
+	ruleReferencedStale     = "referenced-but-stale"
+	ruleOrphan              = "orphan"
+	ruleRotationDisabled    = "rotation-disabled"
+	ruleRotationOverdue     = "rotation-overdue"
+	ruleDeprecatedVersions  = "deprecated-versions"
+	ruleNoDescription       = "no-description"
+	ruleMissingOwner        = "missing-owner-tag"
+	ruleDefaultKMSKey       = "default-kms-key"
+	rulePublicPolicy        = "policy-allows-any-principal"
+	ruleDeepCheckFailed     = "deep-check-failed"
+	ruleCloudTrailFailed    = "cloudtrail-lookup-failed"
+	ruleCloudTrailTruncated = "cloudtrail-lookup-truncated"
+)
+
+// describedStore is implemented by stores that list secrets with their
+// descriptions
+type describedStore interface {
+	ListsDescriptions()
+}
+
+// kmsKeyedStore is implemented by stores whose secrets are encrypted with a
+// KMS key
+type kmsKeyedStore interface {
+	// DefaultKMSKey is the alias of the AWS managed key used when no key
+	// is chosen
+	DefaultKMSKey() string
+}
+
+// taggedStore is implemented by stores that list secrets with their tags;
+// elsewhere a missing tag can't be told from one that was never loaded
+type taggedStore interface {
+	ListsTags()
+}
+
+// ResourcePolicyReader is implemented by stores that attach resource
+// policies to secrets
+type ResourcePolicyReader interface {
+	// GetResourcePolicy returns the secret's policy document, or "" if it
+	// has none
+	GetResourcePolicy(ctx context.Context, secretName string) (string, error)
+}
+
+// secretFacts is everything the rules look at for one secret
+type secretFacts struct {
+	entry           SecretEntry
+	store           SecretStore
+	daysSinceAccess int
+	threshold       int
+	config          *FindingsConfig
+	now             time.Time
+
+	// Only set when files or clusters were searched for references;
+	// referencesSearched is false otherwise
+	referencesSearched bool
+	references         []Reference
+	workloads          []Workload
+
+	// Only gathered for deep checks; deep is false otherwise
+	deep     bool
+	versions []SecretVersion
+	policy   string
+}
+
+// findingRule checks a secret for one kind of problem
+type findingRule struct {
+	id       string
+	severity Severity
+	check    func(f secretFacts) (message string, found bool)
+}
+
+var findingRules = []findingRule{
+	{ruleUnused, SeverityMedium, func(f secretFacts) (string, bool) {
+		return fmt.Sprintf("not accessed in %d days (threshold %d)", f.daysSinceAccess, f.threshold), f.daysSinceAccess > f.threshold
+	}},
+	{ruleNeverAccessed, SeverityHigh, func(f secretFacts) (string, bool) {
+		// New secrets get until the threshold to be read for the first time
+		return fmt.Sprintf("never accessed since it was created %d days ago", f.daysSinceAccess), f.entry.LastAccessedDate == nil && f.daysSinceAccess > f.threshold
+	}},
+	{ruleReferencedStale, SeverityMedium, func(f secretFacts) (string, bool) {
+		// Something still expects the secret; deleting it may break a
+		// deploy, or the workload may be dead or failing to sync
+		var by []string
+		if len(f.workloads) > 0 {
+			by = append(by, plural(len(f.workloads), "workload"))
+		}
+		if len(f.references) > 0 {
+			by = append(by, plural(len(f.references), "file reference"))
+		}
+		return fmt.Sprintf("not accessed in %d days but referenced by %s", f.daysSinceAccess, strings.Join(by, " and ")), f.daysSinceAccess > f.threshold && len(by) > 0
+	}},
+	{ruleOrphan, SeverityHigh, func(f secretFacts) (string, bool) {
+		orphaned := f.referencesSearched && f.daysSinceAccess > f.threshold && len(f.workloads) == 0 && len(f.references) == 0
+		return fmt.Sprintf("not accessed in %d days and referenced by no workload or file searched", f.daysSinceAccess), orphaned
+	}},
+	{ruleRotationDisabled, SeverityLow, func(f secretFacts) (string, bool) {
+		_, rotates := f.store.(SecretRotator)
+		return "automatic rotation is not enabled", rotates && !f.entry.Rotation.Enabled
+	}},
+	{ruleRotationOverdue, SeverityHigh, func(f secretFacts) (string, bool) {
+		rotation := f.entry.Rotation
+		message := fmt.Sprintf("rotation (%s) is overdue", rotation.Rules())
+		if rotation.NextRotationDate != nil {
+			message = fmt.Sprintf("rotation (%s) was due on %s", rotation.Rules(), formatDate(rotation.NextRotationDate))
+		}
+		return message, rotation.Overdue(f.entry.CreatedDate, f.now)
+	}},
+	{ruleDeprecatedVersions, SeverityLow, func(f secretFacts) (string, bool) {
+		if _, staged := f.store.(StageUpdater); !staged || !f.deep {
+			return "", false
+		}
+		deprecated := 0
+		for _, v := range f.versions {
+			if len(v.Stages) == 0 {
+				deprecated++
+			}
+		}
+		return fmt.Sprintf("%d deprecated versions (more than %d)", deprecated, f.config.MaxDeprecatedVersions), deprecated > f.config.MaxDeprecatedVersions
+	}},
+	{ruleNoDescription, SeverityInfo, func(f secretFacts) (string, bool) {
+		_, described := f.store.(describedStore)
+		return "has no description", described && strings.TrimSpace(f.entry.Description) == ""
+	}},
+	{ruleMissingOwner, SeverityLow, func(f secretFacts) (string, bool) {
+		if _, tagged := f.store.(taggedStore); !tagged {
+			return "", false
+		}
+		return fmt.Sprintf("has no %q tag", f.config.OwnerTag), f.entry.Tags[f.config.OwnerTag] == ""
+	}},
+	{ruleDefaultKMSKey, SeverityLow, func(f secretFacts) (string, bool) {
+		keyed, ok := f.store.(kmsKeyedStore)
+		if !ok {
+			return "", false
+		}
+		key := f.entry.KmsKeyId
+		return fmt.Sprintf("encrypted with the AWS managed key %s", keyed.DefaultKMSKey()), key == "" || key == keyed.DefaultKMSKey()
+	}},
+	{rulePublicPolicy, SeverityCritical, func(f secretFacts) (string, bool) {
+		if !f.deep || f.policy == "" {
+			return "", false
+		}
+		return "resource policy allows any principal (\"*\") without conditions", policyAllowsAnyPrincipal(f.policy)
+	}},
+}
+
+// runRules returns the findings of every enabled rule, most severe first
+func runRules(f secretFacts) []Finding {
+	var findings []Finding
+	for _, rule := range findingRules {
+		if slices.Contains(f.config.Disable, rule.id) {
+			continue
+		}
+		if message, found := rule.check(f); found {
+			findings = append(findings, Finding{Rule: rule.id, Severity: rule.severity, Message: message})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity > findings[j].Severity
+	})
+	return findings
+}
+
+// gatherDeepFacts makes the per-secret calls the deep rules need
+func gatherDeepFacts(ctx context.Context, f *secretFacts) error {
+	f.deep = true
+	if _, staged := f.store.(StageUpdater); staged {
+		versions, err := f.store.ListSecretVersions(ctx, f.entry.Name)
+		if err != nil {
+			return err
+		}
+		f.versions = versions
+	}
+	if reader, ok := f.store.(ResourcePolicyReader); ok {
+		policy, err := reader.GetResourcePolicy(ctx, f.entry.Name)
+		if err != nil {
+			return err
+		}
+		f.policy = policy
+	}
+	return nil
+}
+
+// policyAllowsAnyPrincipal reports whether an IAM policy document has an
+// Allow statement for principal "*" that no condition narrows down
+func policyAllowsAnyPrincipal(document string) bool {
+	var policy struct {
+		Statement json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return false
+	}
+
+	// Statement is either a single statement or a list of them
+	type statement struct {
+		Effect    string
+		Principal any
+		Condition map[string]any
+	}
+	var statements []statement
+	if err := json.Unmarshal(policy.Statement, &statements); err != nil {
+		var single statement
+		if json.Unmarshal(policy.Statement, &single) != nil {
+			return false
+		}
+		statements = []statement{single}
+	}
+
+	for _, s := range statements {
+		if s.Effect == "Allow" && len(s.Condition) == 0 && principalIsAny(s.Principal) {
+			return true
+		}
+	}
+	return false
+}
+
+// principalIsAny matches "*" and {"AWS": "*"}, including "*" in a list
+func principalIsAny(principal any) bool {
+	switch p := principal.(type) {
+	case string:
+		return p == "*"
+	case []any:
+		return slices.Contains(p, any("*"))
+	case map[string]any:
+		for _, v := range p {
+			if principalIsAny(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MaxSeverity is the severity of the result's worst finding; ok is false if
+// it has none
+func (r SecretResult) MaxSeverity() (severity Severity, ok bool) {
+	if len(r.Findings) == 0 {
+		return 0, false
+	}
+	// Findings are sorted most severe first
+	return r.Findings[0].Severity, true
+}
+
+// hasFindingAtLeast reports whether the result has a finding of at least the
+// given severity
+func (r SecretResult) hasFindingAtLeast(min Severity) bool {
+	severity, ok := r.MaxSeverity()
+	return ok && severity >= min
+}
+
+// flagged reports whether a scan reports the result: potentially unused
+// secrets always, whatever the minimum severity, and other secrets when a
+// finding reaches it
+func (r SecretResult) flagged(min Severity) bool {
+	return r.Unused || r.hasFindingAtLeast(min)
+}
+
+// formatSeverity summarizes a result's findings for a table cell
+func formatSeverity(result SecretResult) string {
+	severity, ok := result.MaxSeverity()
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%d)", severity, len(result.Findings))
+}
+
+// plural counts a noun: "1 workload", "2 workloads"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// formatFindingRules lists the rules a result broke, most severe first
+func formatFindingRules(findings []Finding) string {
+	rules := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		rules = append(rules, finding.Rule)
+	}
+	return strings.Join(rules, ", ")
+}
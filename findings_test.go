@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPolicyAllowsAnyPrincipal(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     bool
+	}{
+		{"any principal", `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"secretsmanager:GetSecretValue"}]}`, true},
+		{"any AWS principal", `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"*"}}]}`, true},
+		{"any principal in a list", `{"Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::111111111111:root","*"]}}]}`, true},
+		{"single statement", `{"Statement":{"Effect":"Allow","Principal":"*"}}`, true},
+		{"narrowed by a condition", `{"Statement":[{"Effect":"Allow","Principal":"*","Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-1"}}}]}`, false},
+		{"deny", `{"Statement":[{"Effect":"Deny","Principal":"*"}]}`, false},
+		{"named principal", `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::111111111111:root"}}]}`, false},
+		{"invalid", `not json`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policyAllowsAnyPrincipal(tt.document); got != tt.want {
+				t.Errorf("policyAllowsAnyPrincipal() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreCapabilityRules(t *testing.T) {
+	config := &FindingsConfig{OwnerTag: defaultOwnerTag}
+	gated := []string{ruleNoDescription, ruleMissingOwner, ruleDefaultKMSKey}
+
+	tests := []struct {
+		name  string
+		store SecretStore
+		want  []string
+	}{
+		{"Secrets Manager", &AWSSecretsManager{}, []string{ruleNoDescription, ruleMissingOwner, ruleDefaultKMSKey}},
+		// DescribeParameters doesn't return tags
+		{"Parameter Store", &AWSParameterStore{}, []string{ruleNoDescription, ruleDefaultKMSKey}},
+		// Vault secrets have no description or KMS key
+		{"Vault", &VaultKVStore{}, []string{ruleMissingOwner}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, finding := range runRules(secretFacts{entry: SecretEntry{Name: "app/db"}, store: tt.store, threshold: 90, config: config}) {
+				if slices.Contains(gated, finding.Rule) {
+					got = append(got, finding.Rule)
+				}
+			}
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(tt.want))
+			if !slices.Equal(got, want) {
+				t.Errorf("findings = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -28,7 +28,7 @@
 
           src = ./.;
 
-          vendorHash = "sha256-5HhG2GAvf6COM4qN0YZU6kQDiXTcrkjj7Itfee2vK6E=";
+          vendorHash = "sha256-cr5d2BOeAXhTsDcbKz3uhlEH3lisX7AijjYlvRNo0io=";
 
           meta = with pkgs.lib; {
             description = "A tool for finding unused secrets";
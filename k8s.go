@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Services a Kubernetes object can read secrets from, as the External
+// Secrets Operator spells them
+const (
+	serviceSecretsManager = "SecretsManager"
+	serviceParameterStore = "ParameterStore"
+)
+
+// k8sResources are the custom resources read from clusters with kubectl.
+// SecretStores are read for the service and region their ExternalSecrets
+// read from.
+var k8sResources = []string{
+	"externalsecrets.external-secrets.io",
+	"secretstores.external-secrets.io",
+	"clustersecretstores.external-secrets.io",
+	"secretproviderclasses.secrets-store.csi.x-k8s.io",
+}
+
+// Workload is a Kubernetes object that pulls a secret into a cluster
+type Workload struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Source is the manifest file or kube context the object was read from
+	Source string `json:"source"`
+}
+
+// ID names the object by kind, namespace and name
+func (w Workload) ID() string {
+	if w.Namespace == "" {
+		return w.Kind + " " + w.Name
+	}
+	return w.Kind + " " + w.Namespace + "/" + w.Name
+}
+
+func (w Workload) String() string {
+	return fmt.Sprintf("%s (%s)", w.ID(), w.Source)
+}
+
+// workloadRef is one secret a workload reads. Service and region are empty
+// when the manifests don't say.
+type workloadRef struct {
+	workload Workload
+	service  string
+	region   string
+}
+
+// workloadIndex maps the secret names and ARNs that workloads read to the
+// workloads reading them
+type workloadIndex map[string][]workloadRef
+
+// Lookup returns the workloads that read a secret from the given service
+func (idx workloadIndex) Lookup(entry SecretEntry, service string) []Workload {
+	var workloads []Workload
+	seen := map[Workload]bool{}
+	for _, key := range secretKeys(entry) {
+		for _, ref := range idx[key] {
+			if ref.service != "" && service != "" && ref.service != service {
+				continue
+			}
+			if ref.region != "" && entry.Region != "" && ref.region != entry.Region {
+				continue
+			}
+			if !seen[ref.workload] {
+				seen[ref.workload] = true
+				workloads = append(workloads, ref.workload)
+			}
+		}
+	}
+	return workloads
+}
+
+// servicedStore is implemented by stores that Kubernetes objects can read
+// from
+type servicedStore interface {
+	// Service names the service the store reads, as manifests spell it
+	Service() string
+}
+
+// storeService names the service a store reads, or "" if manifests can't
+// name it
+func storeService(store SecretStore) string {
+	if serviced, ok := store.(servicedStore); ok {
+		return serviced.Service()
+	}
+	return ""
+}
+
+type k8sMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+type externalSecret struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     struct {
+		SecretStoreRef struct {
+			Name string `yaml:"name"`
+			Kind string `yaml:"kind"`
+		} `yaml:"secretStoreRef"`
+		Data []struct {
+			RemoteRef struct {
+				Key string `yaml:"key"`
+			} `yaml:"remoteRef"`
+		} `yaml:"data"`
+		DataFrom []struct {
+			Extract struct {
+				Key string `yaml:"key"`
+			} `yaml:"extract"`
+			// v1alpha1 put the key directly in dataFrom
+			Key string `yaml:"key"`
+		} `yaml:"dataFrom"`
+	} `yaml:"spec"`
+}
+
+// secretStore is a SecretStore or ClusterSecretStore
+type secretStore struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     struct {
+		Provider struct {
+			AWS *struct {
+				Service string `yaml:"service"`
+				Region  string `yaml:"region"`
+			} `yaml:"aws"`
+		} `yaml:"provider"`
+	} `yaml:"spec"`
+}
+
+type secretProviderClass struct {
+	Metadata k8sMetadata `yaml:"metadata"`
+	Spec     struct {
+		Provider   string `yaml:"provider"`
+		Parameters struct {
+			Region string `yaml:"region"`
+			// Objects is a YAML document in a string
+			Objects string `yaml:"objects"`
+		} `yaml:"parameters"`
+	} `yaml:"spec"`
+}
+
+// k8sObjects collects the objects read from one cluster, or from every
+// manifest directory; ExternalSecrets are resolved against the stores
+// collected with them
+type k8sObjects struct {
+	index           workloadIndex
+	externalSecrets []sourcedExternalSecret
+	stores          map[string]secretStore
+}
+
+type sourcedExternalSecret struct {
+	source string
+	object externalSecret
+}
+
+func newK8sObjects(index workloadIndex) *k8sObjects {
+	return &k8sObjects{index: index, stores: map[string]secretStore{}}
+}
+
+// add reads every object in a YAML stream. Kinds other than the ones that
+// read secrets are ignored, as are List items of other kinds.
+func (o *k8sObjects) add(source string, r io.Reader) error {
+	dec := yaml.NewDecoder(r)
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := o.addNode(source, &doc); err != nil {
+			return err
+		}
+	}
+}
+
+func (o *k8sObjects) addNode(source string, node *yaml.Node) error {
+	var head struct {
+		Kind  string      `yaml:"kind"`
+		Items []yaml.Node `yaml:"items"`
+	}
+	if err := node.Decode(&head); err != nil {
+		// Not an object, e.g. a document of plain values
+		return nil
+	}
+
+	switch head.Kind {
+	case "List":
+		for i := range head.Items {
+			if err := o.addNode(source, &head.Items[i]); err != nil {
+				return err
+			}
+		}
+	case "ExternalSecret":
+		var es externalSecret
+		if err := node.Decode(&es); err != nil {
+			return err
+		}
+		o.externalSecrets = append(o.externalSecrets, sourcedExternalSecret{source, es})
+	case "SecretStore", "ClusterSecretStore":
+		var store secretStore
+		if err := node.Decode(&store); err != nil {
+			return err
+		}
+		o.stores[storeKey(head.Kind, store.Metadata.Namespace, store.Metadata.Name)] = store
+	case "SecretProviderClass":
+		var spc secretProviderClass
+		if err := node.Decode(&spc); err != nil {
+			return err
+		}
+		return o.addProviderClass(source, spc)
+	}
+	return nil
+}
+
+func storeKey(kind, namespace, name string) string {
+	if kind == "ClusterSecretStore" {
+		return kind + "/" + name
+	}
+	return "SecretStore/" + namespace + "/" + name
+}
+
+func (o *k8sObjects) addProviderClass(source string, spc secretProviderClass) error {
+	if spc.Spec.Provider != "aws" {
+		return nil
+	}
+	var objects []struct {
+		ObjectName string `yaml:"objectName"`
+		ObjectType string `yaml:"objectType"`
+	}
+	if err := yaml.Unmarshal([]byte(spc.Spec.Parameters.Objects), &objects); err != nil {
+		return fmt.Errorf("SecretProviderClass %s/%s objects: %w", spc.Metadata.Namespace, spc.Metadata.Name, err)
+	}
+
+	workload := Workload{Kind: "SecretProviderClass", Namespace: spc.Metadata.Namespace, Name: spc.Metadata.Name, Source: source}
+	for _, object := range objects {
+		ref := workloadRef{workload: workload, region: spc.Spec.Parameters.Region}
+		switch object.ObjectType {
+		case "secretsmanager":
+			ref.service = serviceSecretsManager
+		case "ssmparameter":
+			ref.service = serviceParameterStore
+		}
+		o.index.add(object.ObjectName, ref)
+	}
+	return nil
+}
+
+// resolve indexes the ExternalSecrets with the service and region of the
+// stores they read from. A store that wasn't found matches any service.
+func (o *k8sObjects) resolve() {
+	for _, es := range o.externalSecrets {
+		spec := es.object.Spec
+		ref := workloadRef{workload: Workload{
+			Kind:      "ExternalSecret",
+			Namespace: es.object.Metadata.Namespace,
+			Name:      es.object.Metadata.Name,
+			Source:    es.source,
+		}}
+		kind := spec.SecretStoreRef.Kind
+		if kind == "" {
+			kind = "SecretStore"
+		}
+		if store, ok := o.stores[storeKey(kind, es.object.Metadata.Namespace, spec.SecretStoreRef.Name)]; ok {
+			provider := store.Spec.Provider.AWS
+			if provider == nil {
+				continue // the store reads from another provider
+			}
+			ref.service, ref.region = provider.Service, provider.Region
+		}
+
+		for _, data := range spec.Data {
+			o.index.add(data.RemoteRef.Key, ref)
+		}
+		for _, data := range spec.DataFrom {
+			o.index.add(data.Extract.Key, ref)
+			o.index.add(data.Key, ref)
+		}
+	}
+}
+
+func (idx workloadIndex) add(key string, ref workloadRef) {
+	if key == "" {
+		return
+	}
+	// An ARN says which service and region it is in
+	if parts := strings.SplitN(key, ":", 6); len(parts) == 6 && parts[0] == "arn" {
+		ref.region = parts[3]
+		switch parts[2] {
+		case "secretsmanager":
+			ref.service = serviceSecretsManager
+		case "ssm":
+			ref.service = serviceParameterStore
+		}
+	}
+	idx[key] = append(idx[key], ref)
+}
+
+// loadWorkloads reads the objects that pull secrets into clusters from
+// manifest directories and kube contexts
+func loadWorkloads(ctx context.Context, cfg KubernetesConfig) (workloadIndex, error) {
+	index := workloadIndex{}
+
+	if len(cfg.Manifests) > 0 {
+		objects := newK8sObjects(index)
+		for _, dir := range cfg.Manifests {
+			if err := objects.addManifests(dir); err != nil {
+				return nil, fmt.Errorf("failed to read manifests in %s: %w", dir, err)
+			}
+		}
+		objects.resolve()
+	}
+
+	for _, kubeContext := range cfg.Contexts {
+		objects := newK8sObjects(index)
+		for _, resource := range k8sResources {
+			if err := objects.addFromCluster(ctx, kubeContext, resource); err != nil {
+				return nil, fmt.Errorf("failed to read %s from context %s: %w", resource, kubeContext, err)
+			}
+		}
+		objects.resolve()
+	}
+
+	return index, nil
+}
+
+// addManifests reads every YAML and JSON file under dir. Files that don't
+// parse, such as Helm templates, are skipped.
+func (o *k8sObjects) addManifests(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && referenceSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// Parse into a scratch collection first so a file that fails
+		// halfway adds nothing
+		scratch := newK8sObjects(workloadIndex{})
+		if scratch.add(path, bytes.NewReader(data)) != nil {
+			return nil
+		}
+		return o.add(path, bytes.NewReader(data))
+	})
+}
+
+// addFromCluster lists a resource in every namespace with kubectl. Clusters
+// without the resource's CRD are skipped.
+func (o *k8sObjects) addFromCluster(ctx context.Context, kubeContext, resource string) error {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", "--context", kubeContext, "get", resource, "--all-namespaces", "--output", "yaml")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "the server doesn't have a resource type") {
+			return nil
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("%w: %s", err, message)
+		}
+		return err
+	}
+	return o.add("context "+kubeContext, &stdout)
+}
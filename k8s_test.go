@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var testManifests = map[string]string{
+	"eso/stores.yaml": `
+apiVersion: external-secrets.io/v1beta1
+kind: SecretStore
+metadata:
+  name: aws-sm
+  namespace: apps
+spec:
+  provider:
+    aws:
+      service: SecretsManager
+      region: us-east-1
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ClusterSecretStore
+metadata:
+  name: params
+spec:
+  provider:
+    aws:
+      service: ParameterStore
+      region: eu-west-1
+---
+apiVersion: external-secrets.io/v1beta1
+kind: SecretStore
+metadata:
+  name: vault
+  namespace: apps
+spec:
+  provider:
+    vault:
+      server: https://vault.example.com
+`,
+	"eso/external-secrets.yaml": `
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: db
+  namespace: apps
+spec:
+  secretStoreRef:
+    name: aws-sm
+  data:
+  - secretKey: password
+    remoteRef:
+      key: prod/db
+  dataFrom:
+  - extract:
+      key: prod/config
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: api-key
+  namespace: apps
+spec:
+  secretStoreRef:
+    kind: ClusterSecretStore
+    name: params
+  data:
+  - remoteRef:
+      key: /prod/api-key
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: from-vault
+  namespace: apps
+spec:
+  secretStoreRef:
+    name: vault
+  data:
+  - remoteRef:
+      key: prod/db
+`,
+	// A store in another namespace isn't the one the ExternalSecret reads
+	// from, so it matches any service and region
+	"eso/list.json": `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "apiVersion": "external-secrets.io/v1beta1",
+      "kind": "ExternalSecret",
+      "metadata": {"name": "legacy", "namespace": "batch"},
+      "spec": {
+        "secretStoreRef": {"name": "aws-sm"},
+        "dataFrom": [{"key": "prod/db"}]
+      }
+    },
+    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "prod/db"}}
+  ]
+}`,
+	"csi/spc.yaml": `
+apiVersion: secrets-store.csi.x-k8s.io/v1
+kind: SecretProviderClass
+metadata:
+  name: db
+  namespace: apps
+spec:
+  provider: aws
+  parameters:
+    region: us-west-2
+    objects: |
+      - objectName: prod/db
+        objectType: secretsmanager
+      - objectName: "arn:aws:ssm:eu-west-1:111111111111:parameter/prod/api-key"
+`,
+	// Helm templates don't parse and are skipped
+	"chart/templates/external-secret.yaml": `
+kind: ExternalSecret
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  data:
+  - remoteRef:
+      key: {{ .Values.secret }}
+`,
+	"node_modules/x/external-secret.yaml": `
+kind: ExternalSecret
+metadata:
+  name: vendored
+spec:
+  data:
+  - remoteRef:
+      key: prod/db
+`,
+}
+
+func TestLoadWorkloads(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range testManifests {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	index, err := loadWorkloads(context.Background(), KubernetesConfig{Manifests: []string{dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		entry   SecretEntry
+		service string
+		want    []string
+	}{
+		{
+			name:    "Secrets Manager in the store's region",
+			entry:   SecretEntry{Name: "prod/db", Region: "us-east-1"},
+			service: serviceSecretsManager,
+			want:    []string{"ExternalSecret apps/db", "ExternalSecret batch/legacy"},
+		},
+		{
+			name:    "Secrets Manager in the provider class's region",
+			entry:   SecretEntry{Name: "prod/db", Region: "us-west-2"},
+			service: serviceSecretsManager,
+			want:    []string{"ExternalSecret batch/legacy", "SecretProviderClass apps/db"},
+		},
+		{
+			name:    "same name in Parameter Store",
+			entry:   SecretEntry{Name: "prod/db", Region: "us-east-1"},
+			service: serviceParameterStore,
+			want:    []string{"ExternalSecret batch/legacy"},
+		},
+		{
+			name:    "extracted",
+			entry:   SecretEntry{Name: "prod/config", Region: "us-east-1"},
+			service: serviceSecretsManager,
+			want:    []string{"ExternalSecret apps/db"},
+		},
+		{
+			name:    "ClusterSecretStore and ARN",
+			entry:   SecretEntry{Name: "/prod/api-key", ARN: "arn:aws:ssm:eu-west-1:111111111111:parameter/prod/api-key", Region: "eu-west-1"},
+			service: serviceParameterStore,
+			want:    []string{"ExternalSecret apps/api-key", "SecretProviderClass apps/db"},
+		},
+		{
+			name:    "ARN in another region",
+			entry:   SecretEntry{Name: "/prod/api-key", ARN: "arn:aws:ssm:us-east-1:111111111111:parameter/prod/api-key", Region: "us-east-1"},
+			service: serviceParameterStore,
+		},
+		{
+			name:  "Vault",
+			entry: SecretEntry{Name: "prod/db"},
+			want:  []string{"ExternalSecret apps/db", "ExternalSecret batch/legacy", "SecretProviderClass apps/db"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, workload := range index.Lookup(tt.entry, tt.service) {
+				got = append(got, workload.ID())
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup(%s, %s) = %q, want %q", tt.entry.Name, tt.service, got, tt.want)
+			}
+		})
+	}
+}
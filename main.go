@@ -2,18 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/atotto/clipboard"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -62,56 +68,144 @@ var (
 
 // AWS integration
 type AWSSecretsManager struct {
-	client *secretsmanager.Client
+	client   *secretsmanager.Client
+	account  string
+	region   string
+	identity string
+	trail    *cloudTrail
 }
 
-func NewAWSSecretsManager() (*AWSSecretsManager, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %w", err)
+func NewAWSSecretsManager(cfg aws.Config, account, identity string) *AWSSecretsManager {
+	return &AWSSecretsManager{
+		client:   secretsmanager.NewFromConfig(cfg),
+		account:  account,
+		region:   cfg.Region,
+		identity: identity,
+		trail:    newCloudTrail(cfg),
 	}
+}
 
-	return &AWSSecretsManager{
-		client: secretsmanager.NewFromConfig(cfg),
-	}, nil
+func (sm *AWSSecretsManager) Location() (string, string) {
+	return sm.account, sm.region
+}
+
+// Identity is the ARN of the caller the store acts as
+func (sm *AWSSecretsManager) Identity(context.Context) string {
+	return sm.identity
 }
 
 type SecretEntry struct {
 	Name             string
+	ARN              string
+	Account          string
+	Region           string
 	CreatedDate      *time.Time
 	LastAccessedDate *time.Time
+	DeletedDate      *time.Time
+	Tags             map[string]string
+	Rotation         RotationSettings
+	Description      string
+	KmsKeyId         string
+}
+
+func (sm *AWSSecretsManager) Service() string {
+	return serviceSecretsManager
+}
+
+// ListsTags marks that ListSecrets returns every secret's tags
+func (sm *AWSSecretsManager) ListsTags() {}
+
+// ListsDescriptions marks that ListSecrets returns every secret's description
+func (sm *AWSSecretsManager) ListsDescriptions() {}
+
+// DefaultKMSKey is the key Secrets Manager encrypts with when none is chosen;
+// ListSecrets leaves KmsKeyId empty for it
+func (sm *AWSSecretsManager) DefaultKMSKey() string {
+	return "alias/aws/secretsmanager"
+}
+
+func (sm *AWSSecretsManager) ListSecretPages(ctx context.Context, fn func(page []SecretEntry) error) error {
+	return sm.listSecretPages(ctx, false, fn)
+}
+
+// ListPendingDeletion lists the secrets that are scheduled for deletion and
+// can still be restored
+func (sm *AWSSecretsManager) ListPendingDeletion(ctx context.Context) ([]SecretEntry, error) {
+	secrets, err := collectPages(ctx, func(ctx context.Context, fn func([]SecretEntry) error) error {
+		return sm.listSecretPages(ctx, true, fn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []SecretEntry
+	for _, secret := range secrets {
+		if secret.DeletedDate != nil {
+			pending = append(pending, secret)
+		}
+	}
+
+	return pending, nil
 }
 
-func (sm *AWSSecretsManager) ListSecrets(ctx context.Context) ([]SecretEntry, error) {
-	var secrets []SecretEntry
+func (sm *AWSSecretsManager) listSecretPages(ctx context.Context, includePlannedDeletion bool, fn func(page []SecretEntry) error) error {
+	input := &secretsmanager.ListSecretsInput{
+		IncludePlannedDeletion: aws.Bool(includePlannedDeletion),
+	}
 
-	paginator := secretsmanager.NewListSecretsPaginator(sm.client, &secretsmanager.ListSecretsInput{})
+	paginator := secretsmanager.NewListSecretsPaginator(sm.client, input)
 
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list secrets: %w", err)
+			return fmt.Errorf("failed to list secrets: %w", err)
 		}
 
+		var secrets []SecretEntry
 		for _, secret := range page.SecretList {
-			if secret.Name != nil && !strings.HasSuffix(*secret.Name, "-configuration") {
+			if secret.Name != nil {
 				if secret.CreatedDate == nil {
 					continue // Skip if no creation date
 				}
+				tags := make(map[string]string, len(secret.Tags))
+				for _, tag := range secret.Tags {
+					tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+				}
+				rotation := RotationSettings{
+					Enabled:          aws.ToBool(secret.RotationEnabled),
+					LambdaARN:        aws.ToString(secret.RotationLambdaARN),
+					LastRotatedDate:  secret.LastRotatedDate,
+					NextRotationDate: secret.NextRotationDate,
+				}
+				if rules := secret.RotationRules; rules != nil {
+					rotation.AfterDays = int(aws.ToInt64(rules.AutomaticallyAfterDays))
+					rotation.Schedule = aws.ToString(rules.ScheduleExpression)
+				}
 				secrets = append(secrets, SecretEntry{
 					Name:             *secret.Name,
+					ARN:              aws.ToString(secret.ARN),
+					Account:          sm.account,
+					Region:           sm.region,
 					CreatedDate:      secret.CreatedDate,
 					LastAccessedDate: secret.LastAccessedDate,
+					DeletedDate:      secret.DeletedDate,
+					Tags:             tags,
+					Rotation:         rotation,
+					Description:      aws.ToString(secret.Description),
+					KmsKeyId:         aws.ToString(secret.KmsKeyId),
 				})
 			}
 		}
+		if err := fn(secrets); err != nil {
+			return err
+		}
 	}
 
-	return secrets, nil
+	return nil
 }
 
-func (sm *AWSSecretsManager) ListSecretVersions(ctx context.Context, secretName string) ([]types.SecretVersionsListEntry, error) {
-	var versions []types.SecretVersionsListEntry
+func (sm *AWSSecretsManager) ListSecretVersions(ctx context.Context, secretName string) ([]SecretVersion, error) {
+	var versions []SecretVersion
 
 	input := &secretsmanager.ListSecretVersionIdsInput{
 		SecretId:          aws.String(secretName),
@@ -127,13 +221,20 @@ func (sm *AWSSecretsManager) ListSecretVersions(ctx context.Context, secretName
 			return nil, fmt.Errorf("failed to list secret versions: %w", err)
 		}
 
-		versions = append(versions, page.Versions...)
+		for _, v := range page.Versions {
+			versions = append(versions, SecretVersion{
+				VersionId:        aws.ToString(v.VersionId),
+				CreatedDate:      v.CreatedDate,
+				LastAccessedDate: v.LastAccessedDate,
+				Stages:           v.VersionStages,
+			})
+		}
 	}
 
 	return versions, nil
 }
 
-func (sm *AWSSecretsManager) GetSecretValue(ctx context.Context, secretName, versionId string) (string, error) {
+func (sm *AWSSecretsManager) GetSecretValue(ctx context.Context, secretName, versionId string) (SecretValue, error) {
 	input := &secretsmanager.GetSecretValueInput{
 		SecretId:  aws.String(secretName),
 		VersionId: aws.String(versionId),
@@ -141,16 +242,29 @@ func (sm *AWSSecretsManager) GetSecretValue(ctx context.Context, secretName, ver
 
 	output, err := sm.client.GetSecretValue(ctx, input)
 	if err != nil {
-		return "", fmt.Errorf("failed to get secret value: %w", err)
+		return SecretValue{}, fmt.Errorf("failed to get secret value: %w", err)
 	}
 
-	return *output.SecretString, nil
+	// Secrets stored as binary have no SecretString
+	if output.SecretString == nil {
+		return SecretValue{Binary: output.SecretBinary}, nil
+	}
+	return SecretValue{String: *output.SecretString}, nil
 }
 
-func (sm *AWSSecretsManager) DeleteSecret(ctx context.Context, secretName string) error {
+// AcceptsARN marks that every Secrets Manager operation takes an ARN as its
+// SecretId
+func (sm *AWSSecretsManager) AcceptsARN() {}
+
+func (sm *AWSSecretsManager) DeleteSecret(ctx context.Context, secretName string, opts DeleteOptions) error {
 	input := &secretsmanager.DeleteSecretInput{
 		SecretId: aws.String(secretName),
 	}
+	if opts.Force {
+		input.ForceDeleteWithoutRecovery = aws.Bool(true)
+	} else if opts.RecoveryWindowDays > 0 {
+		input.RecoveryWindowInDays = aws.Int64(int64(opts.RecoveryWindowDays))
+	}
 
 	_, err := sm.client.DeleteSecret(ctx, input)
 	if err != nil {
@@ -160,39 +274,358 @@ func (sm *AWSSecretsManager) DeleteSecret(ctx context.Context, secretName string
 	return nil
 }
 
-// Enhanced secret analysis
-type SecretAnalyzer struct {
-	awsManager *AWSSecretsManager
+func (sm *AWSSecretsManager) RestoreSecret(ctx context.Context, secretName string) error {
+	input := &secretsmanager.RestoreSecretInput{
+		SecretId: aws.String(secretName),
+	}
+
+	_, err := sm.client.RestoreSecret(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to restore secret %s: %w", secretName, err)
+	}
+
+	return nil
 }
 
-func NewSecretAnalyzer() (*SecretAnalyzer, error) {
-	awsManager, err := NewAWSSecretsManager()
+func (sm *AWSSecretsManager) CreateSecret(ctx context.Context, secretName, value string) (WrittenVersion, error) {
+	input := &secretsmanager.CreateSecretInput{
+		Name:         aws.String(secretName),
+		SecretString: aws.String(value),
+	}
+
+	output, err := sm.client.CreateSecret(ctx, input)
 	if err != nil {
-		return nil, err
+		return WrittenVersion{}, fmt.Errorf("failed to create secret %s: %w", secretName, err)
+	}
+
+	return WrittenVersion{ARN: aws.ToString(output.ARN), VersionId: aws.ToString(output.VersionId)}, nil
+}
+
+func (sm *AWSSecretsManager) PutSecretValue(ctx context.Context, secretName, value string, stages []string) (WrittenVersion, error) {
+	input := &secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(secretName),
+		SecretString:  aws.String(value),
+		VersionStages: stages,
+	}
+
+	output, err := sm.client.PutSecretValue(ctx, input)
+	if err != nil {
+		return WrittenVersion{}, fmt.Errorf("failed to put value of secret %s: %w", secretName, err)
+	}
+
+	return WrittenVersion{ARN: aws.ToString(output.ARN), VersionId: aws.ToString(output.VersionId)}, nil
+}
+
+func (sm *AWSSecretsManager) UpdateVersionStage(ctx context.Context, secretName, stage, moveToVersionId, removeFromVersionId string) error {
+	input := &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:     aws.String(secretName),
+		VersionStage: aws.String(stage),
+	}
+	if moveToVersionId != "" {
+		input.MoveToVersionId = aws.String(moveToVersionId)
+	}
+	if removeFromVersionId != "" {
+		input.RemoveFromVersionId = aws.String(removeFromVersionId)
+	}
+
+	_, err := sm.client.UpdateSecretVersionStage(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to update stage %s of secret %s: %w", stage, secretName, err)
+	}
+
+	return nil
+}
+
+func (sm *AWSSecretsManager) GetResourcePolicy(ctx context.Context, secretName string) (string, error) {
+	input := &secretsmanager.GetResourcePolicyInput{
+		SecretId: aws.String(secretName),
+	}
+
+	output, err := sm.client.GetResourcePolicy(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to get resource policy of secret %s: %w", secretName, err)
+	}
+
+	return aws.ToString(output.ResourcePolicy), nil
+}
+
+// RotateSecret starts a rotation with the secret's configured rotation
+// function
+func (sm *AWSSecretsManager) RotateSecret(ctx context.Context, secretName string) error {
+	input := &secretsmanager.RotateSecretInput{
+		SecretId: aws.String(secretName),
 	}
 
+	_, err := sm.client.RotateSecret(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to rotate secret %s: %w", secretName, err)
+	}
+
+	return nil
+}
+
+// LastRead looks up the latest GetSecretValue call on a secret
+func (sm *AWSSecretsManager) LastRead(ctx context.Context, entry SecretEntry, since time.Time, maxEvents int) (*AccessEvidence, bool, error) {
+	return sm.trail.lastRead(ctx, entry, since, maxEvents)
+}
+
+// Enhanced secret analysis
+type SecretAnalyzer struct {
+	config *Config
+	stores []SecretStore
+}
+
+func NewSecretAnalyzer(config *Config, stores ...SecretStore) *SecretAnalyzer {
 	return &SecretAnalyzer{
-		awsManager: awsManager,
-	}, nil
+		config: config,
+		stores: stores,
+	}
+}
+
+// storeListing is a batch of secrets listed from a single store
+type storeListing struct {
+	store   SecretStore
+	secrets []SecretEntry
+
+	// refs index the local files searched for references, and workloads
+	// the Kubernetes objects that read secrets, if any
+	refs      referenceIndex
+	workloads workloadIndex
+}
+
+// ScanProgress describes how far a scan has got
+type ScanProgress struct {
+	// Account and Region are where the latest page came from
+	Account    string
+	Region     string
+	Stores     int
+	StoresDone int
+	Pages      int
+	Listed     int
+	// Analyzed counts the listed secrets the config didn't exclude
+	Analyzed int
+	// Traced counts the results whose last read was looked up in
+	// CloudTrail, out of ToTrace
+	Traced  int
+	ToTrace int
+	// Done sums how far each store has got, from 0 to 1 per store
+	Done float64
+}
+
+// Fraction estimates how much of the scan is done. Stores don't say how many
+// secrets they hold, so each page moves a store half of the way still to go
+// until its listing ends. With CloudTrail lookups, listing is the first half
+// of a store's share and the lookups are the second.
+func (p ScanProgress) Fraction() float64 {
+	if p.Stores == 0 {
+		return 0
+	}
+	return p.Done / float64(p.Stores)
+}
+
+// ScanPage is one page of a store's secrets, analyzed
+type ScanPage struct {
+	Results  []SecretResult
+	Excluded []ExcludedSecret
+	// Traced are results from earlier pages with their last read looked up
+	Traced   []SecretResult
+	Progress ScanProgress
+
+	// storeIndex is the position of the page's store in the analyzer
+	storeIndex int
 }
 
-const recentThresholdDays = 14
+// StreamSecrets lists every store concurrently and calls fn with each page
+// of analyzed secrets as soon as it arrives. Calls to fn are never made
+// concurrently. Pages from other stores are still delivered when one store
+// fails; the failures are returned at the end.
+//
+// Local files are only searched for the secrets that were listed, so when
+// there are references to find, pages are analyzed once every store has
+// been listed and the files indexed.
+func (sa *SecretAnalyzer) StreamSecrets(ctx context.Context, applyFilter bool, fn func(ScanPage)) error {
+	searchRefs := len(sa.config.References) > 0
+	var refs referenceIndex
+	var refsErr error
+	listed := make([][][]SecretEntry, len(sa.stores))
+	var allListed sync.WaitGroup
+	indexed := make(chan struct{})
+
+	var workloads workloadIndex
+	if sa.config.Kubernetes.Enabled() {
+		var err error
+		workloads, err = loadWorkloads(ctx, sa.config.Kubernetes)
+		if err != nil {
+			return err
+		}
+	}
+
+	var mu sync.Mutex
+	progress := ScanProgress{Stores: len(sa.stores)}
+	errs := make([]error, len(sa.stores))
+
+	var wg sync.WaitGroup
+	if searchRefs {
+		allListed.Add(len(sa.stores))
+	}
+	for i, store := range sa.stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			account, region := storeLocation(store)
+			analyze := func(secrets []SecretEntry) ([]SecretResult, []ExcludedSecret) {
+				listing := storeListing{store: store, secrets: secrets, refs: refs, workloads: workloads}
+				return sa.analyzeListing(ctx, listing, applyFilter)
+			}
+			tracer, tracing := store.(AccessTracer)
+			tracing = tracing && sa.config.CloudTrail.Enabled
+
+			// toList is the store's share of progress its listing has yet
+			// to make
+			toList := 1.0
+			if tracing {
+				toList = 0.5
+			}
+			var candidates []SecretResult
+			errs[i] = store.ListSecretPages(ctx, func(secrets []SecretEntry) error {
+				var results []SecretResult
+				var excluded []ExcludedSecret
+				if !searchRefs {
+					results, excluded = analyze(secrets)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				progress.Account, progress.Region = account, region
+				progress.Pages++
+				progress.Listed += len(secrets)
+				progress.Done += toList / 2
+				toList /= 2
+				if searchRefs {
+					listed[i] = append(listed[i], secrets)
+					fn(ScanPage{Progress: progress, storeIndex: i})
+					return nil
+				}
+				progress.Analyzed += len(secrets) - len(excluded)
+				fn(ScanPage{Results: results, Excluded: excluded, Progress: progress, storeIndex: i})
+				candidates = append(candidates, results...)
+				return nil
+			})
+
+			if searchRefs {
+				allListed.Done()
+				<-indexed
+				if refsErr != nil {
+					return
+				}
+				for _, secrets := range listed[i] {
+					results, excluded := analyze(secrets)
+
+					mu.Lock()
+					progress.Analyzed += len(secrets) - len(excluded)
+					fn(ScanPage{Results: results, Excluded: excluded, Progress: progress, storeIndex: i})
+					mu.Unlock()
+					candidates = append(candidates, results...)
+				}
+			}
+
+			// CloudTrail allows a couple of lookups a second, so they run
+			// once the store's pages have been delivered
+			if tracing {
+				mu.Lock()
+				progress.Done += toList
+				toList = 0
+				progress.ToTrace += len(candidates)
+				if len(candidates) == 0 {
+					progress.Done += 0.5
+				}
+				mu.Unlock()
+				for _, result := range candidates {
+					sa.traceRead(ctx, tracer, &result)
+
+					mu.Lock()
+					progress.Traced++
+					progress.Done += 0.5 / float64(len(candidates))
+					fn(ScanPage{Traced: []SecretResult{result}, Progress: progress, storeIndex: i})
+					mu.Unlock()
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			progress.Done += toList
+			progress.StoresDone++
+			fn(ScanPage{Progress: progress, storeIndex: i})
+		}()
+	}
+
+	if searchRefs {
+		allListed.Wait()
+		keys := map[string]bool{}
+		for _, pages := range listed {
+			for _, secrets := range pages {
+				for _, entry := range secrets {
+					for _, key := range secretKeys(entry) {
+						keys[key] = true
+					}
+				}
+			}
+		}
+		refs, refsErr = indexReferences(sa.config.References, keys)
+		close(indexed)
+	}
+	wg.Wait()
+
+	if refsErr != nil {
+		return refsErr
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to fetch secrets: %w", err)
+	}
+	return nil
+}
 
-func (sa *SecretAnalyzer) AnalyzeSecrets(ctx context.Context, applyFilter bool) ([]SecretResult, error) {
-	// Step 1: Get AWS secrets
-	secrets, err := sa.awsManager.ListSecrets(ctx)
+// AnalyzeSecrets lists every store and returns the analyzed secrets along
+// with the secrets that the config excluded from analysis. Results are in
+// store order, so they stay stable between scans.
+func (sa *SecretAnalyzer) AnalyzeSecrets(ctx context.Context, applyFilter bool) ([]SecretResult, []ExcludedSecret, error) {
+	perStore := make([]ScanPage, len(sa.stores))
+	err := sa.StreamSecrets(ctx, applyFilter, func(page ScanPage) {
+		perStore[page.storeIndex].Results = append(perStore[page.storeIndex].Results, page.Results...)
+		perStore[page.storeIndex].Excluded = append(perStore[page.storeIndex].Excluded, page.Excluded...)
+		for _, traced := range page.Traced {
+			mergeTraced(perStore[page.storeIndex].Results, traced)
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch AWS secrets: %w", err)
+		return nil, nil, err
 	}
 
-	if len(secrets) == 0 {
-		return []SecretResult{}, nil
+	results := []SecretResult{}
+	var excluded []ExcludedSecret
+	for _, page := range perStore {
+		results = append(results, page.Results...)
+		excluded = append(excluded, page.Excluded...)
 	}
 
+	return results, excluded, nil
+}
+
+func (sa *SecretAnalyzer) analyzeListing(ctx context.Context, listing storeListing, applyFilter bool) ([]SecretResult, []ExcludedSecret) {
 	var results []SecretResult
+	var excluded []ExcludedSecret
+
+	for _, entry := range listing.secrets {
+		if reason, ok := sa.config.Exclude.Match(entry); ok {
+			excluded = append(excluded, ExcludedSecret{
+				Name:    entry.Name,
+				Account: entry.Account,
+				Region:  entry.Region,
+				Reason:  reason,
+			})
+			continue
+		}
 
-	// Step 2: Analyze each secret
-	for _, entry := range secrets {
 		// Calculate days since access
 		var daysSinceAccess int = 9999
 		var lastAccessedStr string = "Never"
@@ -207,17 +640,60 @@ func (sa *SecretAnalyzer) AnalyzeSecrets(ctx context.Context, applyFilter bool)
 			daysSinceAccess = createdDays
 		}
 
-		if applyFilter && daysSinceAccess <= recentThresholdDays {
-			continue
+		threshold := sa.config.ThresholdFor(entry.Name)
+		facts := secretFacts{
+			entry:           entry,
+			store:           listing.store,
+			daysSinceAccess: daysSinceAccess,
+			threshold:       threshold,
+			config:          &sa.config.Findings,
+			now:             time.Now(),
+
+			referencesSearched: listing.refs != nil || listing.workloads != nil,
+			references:         listing.refs.Lookup(entry),
+			workloads:          listing.workloads.Lookup(entry, storeService(listing.store)),
+		}
+		var deepErr error
+		if sa.config.Findings.Deep {
+			deepErr = gatherDeepFacts(ctx, &facts)
+		}
+		findings := runRules(facts)
+		if deepErr != nil {
+			findings = append(findings, Finding{
+				Rule:     ruleDeepCheckFailed,
+				Severity: SeverityInfo,
+				Message:  deepErr.Error(),
+			})
 		}
 
-		results = append(results, SecretResult{
-			Name:         entry.Name,
-			LastAccessed: lastAccessedStr,
-		})
+		result := SecretResult{
+			Name:            entry.Name,
+			ARN:             entry.ARN,
+			Account:         entry.Account,
+			Region:          entry.Region,
+			LastAccessed:    lastAccessedStr,
+			Tags:            entry.Tags,
+			Unused:          daysSinceAccess > threshold,
+			RotationEnabled: entry.Rotation.Enabled,
+			RotationOverdue: entry.Rotation.Overdue(entry.CreatedDate, facts.now),
+			Findings:        findings,
+			store:           listing.store,
+		}
+		if applyFilter && !result.flagged(sa.config.Findings.minSeverity) {
+			continue
+		}
+		if entry.Rotation.Enabled {
+			result.RotationRules = entry.Rotation.Rules()
+			result.RotationLambda = entry.Rotation.LambdaARN
+			result.LastRotated = formatDate(entry.Rotation.LastRotatedDate)
+			result.NextRotation = formatDate(entry.Rotation.NextRotationDate)
+		}
+		result.References = facts.references
+		result.Workloads = facts.workloads
+		results = append(results, result)
 	}
 
-	return results, nil
+	return results, excluded
 }
 
 // Fuzzy match function
@@ -249,11 +725,54 @@ type VersionInfo struct {
 	Stages       string
 	Value        string
 	Revealed     bool
+	Marked       bool
+
+	// raw is the revealed value as copied to the clipboard; binary values
+	// are base64 encoded
+	raw     string
+	binary  bool
+	stages  []string
+	created time.Time
+}
+
+// ExcludedSecret is a secret that the config excluded from analysis
+type ExcludedSecret struct {
+	Name    string `json:"name"`
+	Account string `json:"account,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Reason  string `json:"reason"`
 }
 
 type SecretResult struct {
-	Name         string
-	LastAccessed string
+	Name         string            `json:"name"`
+	ARN          string            `json:"arn,omitempty"`
+	Account      string            `json:"account,omitempty"`
+	Region       string            `json:"region,omitempty"`
+	LastAccessed string            `json:"last_accessed"`
+	Unused       bool              `json:"unused"`
+	Tags         map[string]string `json:"tags,omitempty"`
+
+	RotationEnabled bool   `json:"rotation_enabled"`
+	RotationRules   string `json:"rotation_rules,omitempty"`
+	RotationLambda  string `json:"rotation_lambda_arn,omitempty"`
+	LastRotated     string `json:"last_rotated,omitempty"`
+	NextRotation    string `json:"next_rotation,omitempty"`
+	RotationOverdue bool   `json:"rotation_overdue"`
+
+	// Findings are sorted most severe first
+	Findings []Finding `json:"findings,omitempty"`
+
+	// LastRead is the latest read CloudTrail recorded, when looked up
+	LastRead *AccessEvidence `json:"last_read,omitempty"`
+	// References are the lines of local files that mention the secret
+	References []Reference `json:"references,omitempty"`
+	// Workloads are the Kubernetes objects that pull the secret into a
+	// cluster
+	Workloads []Workload `json:"workloads,omitempty"`
+
+	// store is the store the secret was listed from; every operation on
+	// the secret goes through it
+	store SecretStore
 }
 
 type model struct {
@@ -262,30 +781,90 @@ type model struct {
 	progress         progress.Model
 	table            table.Model
 	versionTable     table.Model
+	excludedTable    table.Model
+	pendingTable     table.Model
+	deleteTable      table.Model
 	filterInput      textinput.Model
 	scanning         bool
 	results          []SecretResult
+	excluded         []ExcludedSecret
+	pending          []pendingSecret
+	pendingMessage   string
+	pendingLoading   bool
 	baseResults      []SecretResult
 	originalResults  []SecretResult
 	selected         []bool
 	originalSelected []bool
 	analyzer         *SecretAnalyzer
+	audit            *AuditLog
 	currentScanStep  string
+	scanProgress     ScanProgress
 	err              error
-	viewingSecret    string
+	viewing          SecretResult
+	viewError        string
 	versions         []VersionInfo
+	detailTable      table.Model
+	detailFields     []detailField
+	detailVersion    VersionInfo
+	detailJSON       bool
+	detailLoading    bool
+	diff             []diffLine
+	diffJSON         bool
+	diffFrom         VersionInfo
+	diffTo           VersionInfo
+	diffLoading      bool
+	diffRevealed     bool
+	editor           textarea.Model
+	editName         textinput.Model
+	editStages       textinput.Model
+	editCreate       bool
+	editTarget       SecretResult
+	editFocus        int
+	editError        string
+	editSaving       bool
+	writeMessage     string
+	stageInput       textinput.Model
+	stageDetach      bool
+	stageChange      stageChange
+	stageSaving      bool
 	confirmDelete    bool
+	forceDelete      bool
 	deleteError      string
+	deleteTotal      int
+	deleteOutcomes   []deleteOutcome
 	copiedMessage    string
+	copySeq          int
+	clipboardValue   string
+	clipboardClearAt time.Time
 	lastCursorPos    int
 	filterMode       string
 	filtered         bool
 	hasFilter        bool
+	filters          []appliedFilter
+	showTags         bool
+	showRotation     bool
+	showRefs         bool
+	showWorkloads    bool
+	sortBySeverity   bool
+	severityFilter   bool
+	minSeverity      Severity
+	rotateTarget     SecretResult
+	rotating         bool
+	opts             options
+	planMessage      string
+}
+
+// scanPageMsg delivers a page of results while a scan runs; updates
+// delivers the rest of the scan's messages
+type scanPageMsg struct {
+	page    ScanPage
+	updates <-chan tea.Msg
 }
 
+// analysisCompleteMsg ends a scan; its results have already arrived as
+// scanPageMsgs
 type analysisCompleteMsg struct {
-	results []SecretResult
-	err     error
+	err error
 }
 
 type versionsFetchedMsg struct {
@@ -293,36 +872,200 @@ type versionsFetchedMsg struct {
 	err      error
 }
 
-type valueRevealedMsg struct {
+type diffFetchedMsg struct {
+	from   VersionInfo
+	to     VersionInfo
+	lines  []diffLine
+	isJSON bool
+	err    error
+}
+
+type detailFetchedMsg struct {
+	version VersionInfo
+	fields  []secretField
+	isJSON  bool
+	err     error
+}
+
+type fieldRevealedMsg struct {
 	index int
-	value string
 	err   error
 }
 
-type startScanMsg struct{}
+// detailField is a field shown in the secret detail pane
+type detailField struct {
+	secretField
+	Revealed bool
+}
 
-type deleteCompleteMsg struct {
+type diffRevealedMsg struct {
 	err error
 }
 
-type clearCopiedMsg struct{}
+type valueRevealedMsg struct {
+	index  int
+	value  string
+	raw    string
+	binary bool
+	err    error
+}
 
-func initialModel() model {
-	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = uiStyle
+// secretWrittenMsg reports a created secret or a new version of one
+type secretWrittenMsg struct {
+	result  SecretResult
+	version WrittenVersion
+	created bool
+	err     error
+}
 
-	p := progress.New(progress.WithDefaultGradient())
+type startScanMsg struct{}
+
+// pendingSecret is a secret scheduled for deletion, with the store that can
+// restore it
+type pendingSecret struct {
+	entry SecretEntry
+	store SecretRestorer
+}
+
+type pendingFetchedMsg struct {
+	pending []pendingSecret
+	err     error
+}
+
+type restoreCompleteMsg struct {
+	name string
+	err  error
+}
+
+type planWrittenMsg struct {
+	path string
+	err  error
+}
+
+// deleteProgressMsg reports one finished delete; outcomes delivers the rest
+type deleteProgressMsg struct {
+	outcome  deleteOutcome
+	outcomes <-chan deleteOutcome
+}
+
+type deleteCompleteMsg struct{}
+
+// copiedMsg reports a copy to the clipboard. value is set when a secret
+// value was copied, so it can be cleared again.
+type copiedMsg struct {
+	message string
+	value   string
+	err     error
+}
+
+// clearCopiedMsg, clipboardTickMsg and clipboardClearedMsg belong to the
+// copy numbered seq; they are ignored once something else is copied
+type clearCopiedMsg struct {
+	seq int
+}
+
+type clipboardTickMsg struct {
+	seq int
+}
+
+type clipboardClearedMsg struct {
+	seq     int
+	cleared bool
+	err     error
+}
+
+// stageChange is a stage label move waiting for confirmation
+type stageChange struct {
+	stage      string
+	moveTo     string
+	removeFrom string
+}
+
+func (c stageChange) prompt() string {
+	switch {
+	case c.moveTo != "" && c.removeFrom != "":
+		return fmt.Sprintf("Move %s from version %s to %s?", c.stage, c.removeFrom, c.moveTo)
+	case c.moveTo != "":
+		return fmt.Sprintf("Attach %s to version %s?", c.stage, c.moveTo)
+	default:
+		return fmt.Sprintf("Detach %s from version %s?", c.stage, c.removeFrom)
+	}
+}
+
+func (c stageChange) done() string {
+	switch {
+	case c.moveTo != "" && c.removeFrom != "":
+		return fmt.Sprintf("Moved %s from version %s to %s", c.stage, c.removeFrom, c.moveTo)
+	case c.moveTo != "":
+		return fmt.Sprintf("Attached %s to version %s", c.stage, c.moveTo)
+	default:
+		return fmt.Sprintf("Detached %s from version %s", c.stage, c.removeFrom)
+	}
+}
+
+type rotateStartedMsg struct {
+	result SecretResult
+	err    error
+}
+
+type stageUpdatedMsg struct {
+	change stageChange
+	err    error
+}
 
-	// Main table columns: checkbox, Secret, Last Accessed
+// Fields of the secret editor, in tab order
+const (
+	editFocusName = iota
+	editFocusValue
+	editFocusStages
+)
+
+// secretValueLimit is Secrets Manager's limit on the size of a value
+const secretValueLimit = 65536
+
+// maxShownReferences is how many references the secret view lists
+const maxShownReferences = 10
+
+// resultColumns returns the main table columns: checkbox, Secret, Account,
+// Region, Last Accessed, Severity and optionally Refs, Workloads, the
+// rotation columns and Tags
+func resultColumns(showTags, showRotation, showRefs, showWorkloads bool) []table.Column {
 	columns := []table.Column{
 		{Title: "", Width: 3},
 		{Title: "Secret", Width: 40},
+		{Title: "Account", Width: 14},
+		{Title: "Region", Width: 15},
 		{Title: "Last Accessed", Width: 15},
+		{Title: "Severity", Width: 14},
+	}
+	if showRefs {
+		columns = append(columns, table.Column{Title: "Refs", Width: 6})
+	}
+	if showWorkloads {
+		columns = append(columns, table.Column{Title: "Workloads", Width: 10})
+	}
+	if showRotation {
+		columns = append(columns,
+			table.Column{Title: "Rotation", Width: 24},
+			table.Column{Title: "Last Rotated", Width: 13},
+			table.Column{Title: "Next Rotation", Width: 13},
+		)
 	}
+	if showTags {
+		columns = append(columns, table.Column{Title: "Tags", Width: 40})
+	}
+	return columns
+}
+
+func initialModel(opts options) model {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = uiStyle
+
+	p := progress.New(progress.WithDefaultGradient())
 
 	t := table.New(
-		table.WithColumns(columns),
+		table.WithColumns(resultColumns(false, false, false, false)),
 		table.WithFocused(true),
 		table.WithHeight(10),
 	)
@@ -339,8 +1082,9 @@ func initialModel() model {
 		Bold(false)
 	t.SetStyles(tableStyle)
 
-	// Version table columns: Version ID, Created Date, Last Accessed, Stages, Value
+	// Version table columns: mark, Version ID, Created Date, Last Accessed, Stages, Value
 	versionColumns := []table.Column{
+		{Title: "", Width: 3},
 		{Title: "Version ID", Width: 36},
 		{Title: "Created Date", Width: 20},
 		{Title: "Last Accessed", Width: 15},
@@ -355,53 +1099,191 @@ func initialModel() model {
 	)
 	vt.SetStyles(tableStyle)
 
-	fi := textinput.New()
-	fi.Placeholder = "Filter..."
-
-	// Initialize analyzer
-	analyzer, err := NewSecretAnalyzer()
-	if err != nil {
-		analyzer = nil
+	// Excluded table columns: Secret, Account, Region, Reason
+	excludedColumns := []table.Column{
+		{Title: "Secret", Width: 40},
+		{Title: "Account", Width: 14},
+		{Title: "Region", Width: 15},
+		{Title: "Reason", Width: 40},
 	}
 
-	return model{
-		state:           "banner",
-		spinner:         s,
-		progress:        p,
-		table:           t,
-		versionTable:    vt,
-		filterInput:     fi,
-		scanning:        false,
-		analyzer:        analyzer,
-		currentScanStep: "Ready to scan",
-		err:             err,
-		selected:        []bool{},
-		copiedMessage:   "",
-		lastCursorPos:   0,
-		filtered:        true,
-		hasFilter:       false,
+	et := table.New(
+		table.WithColumns(excludedColumns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+	et.SetStyles(tableStyle)
+
+	// Pending deletion table columns: Secret, Account, Region, Deleted On
+	pendingColumns := []table.Column{
+		{Title: "Secret", Width: 40},
+		{Title: "Account", Width: 14},
+		{Title: "Region", Width: 15},
+		{Title: "Deleted On", Width: 20},
 	}
-}
 
-func (m model) Init() tea.Cmd {
-	return tea.Batch(m.spinner.Tick, tea.Tick(time.Second*2, func(t time.Time) tea.Msg {
-		return startScanMsg{}
+	pt := table.New(
+		table.WithColumns(pendingColumns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+	pt.SetStyles(tableStyle)
+
+	// Delete report table columns: Secret, Account, Region, Status
+	deleteColumns := []table.Column{
+		{Title: "Secret", Width: 40},
+		{Title: "Account", Width: 14},
+		{Title: "Region", Width: 15},
+		{Title: "Status", Width: 60},
+	}
+
+	dt := table.New(
+		table.WithColumns(deleteColumns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+	dt.SetStyles(tableStyle)
+
+	// Secret detail table columns: Key, Value
+	detailColumns := []table.Column{
+		{Title: "Key", Width: 30},
+		{Title: "Value", Width: 80},
+	}
+
+	st := table.New(
+		table.WithColumns(detailColumns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+	st.SetStyles(tableStyle)
+
+	fi := textinput.New()
+	fi.Placeholder = "Filter..."
+
+	ed := textarea.New()
+	ed.Placeholder = "Secret value, plain text or JSON"
+	ed.CharLimit = secretValueLimit
+	ed.MaxHeight = 999
+	ed.SetWidth(100)
+	ed.SetHeight(12)
+
+	en := textinput.New()
+	en.Placeholder = "Secret name"
+
+	es := textinput.New()
+	es.Placeholder = "AWSCURRENT"
+
+	sl := textinput.New()
+	sl.Placeholder = "Stage label"
+
+	// Initialize analyzer
+	var analyzer *SecretAnalyzer
+	var audit *AuditLog
+	cfg, err := opts.loadConfig()
+	if err == nil {
+		audit = NewAuditLog(cfg.AuditLog)
+		var stores []SecretStore
+		stores, err = newSecretStores(context.Background(), opts)
+		if err == nil {
+			analyzer = NewSecretAnalyzer(cfg, stores...)
+		}
+	}
+	showRefs := cfg != nil && len(cfg.References) > 0
+	showWorkloads := cfg != nil && cfg.Kubernetes.Enabled()
+	t.SetColumns(resultColumns(false, false, showRefs, showWorkloads))
+
+	return model{
+		state:           "banner",
+		spinner:         s,
+		progress:        p,
+		table:           t,
+		versionTable:    vt,
+		excludedTable:   et,
+		pendingTable:    pt,
+		deleteTable:     dt,
+		detailTable:     st,
+		filterInput:     fi,
+		editor:          ed,
+		editName:        en,
+		editStages:      es,
+		stageInput:      sl,
+		scanning:        false,
+		analyzer:        analyzer,
+		audit:           audit,
+		currentScanStep: "Ready to scan",
+		err:             err,
+		selected:        []bool{},
+		copiedMessage:   "",
+		lastCursorPos:   0,
+		filtered:        true,
+		hasFilter:       false,
+		opts:            opts,
+		showRefs:        showRefs,
+		showWorkloads:   showWorkloads,
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick, tea.Tick(time.Second*2, func(t time.Time) tea.Msg {
+		return startScanMsg{}
 	}))
 }
 
+// typingText reports whether keys go to a text input
+func (m model) typingText() bool {
+	switch m.state {
+	case "edit_secret", "stage_label", "filter_include", "filter_exclude":
+		return true
+	}
+	return false
+}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		key := msg.String()
 
-		if key == "q" || key == "ctrl+c" {
+		// q is text while editing a secret, a label or a filter
+		if key == "ctrl+c" || (key == "q" && !m.typingText()) {
+			// Don't leave a copied secret behind
+			if m.clipboardValue != "" {
+				clearClipboard(m.clipboardValue)
+			}
 			return m, tea.Quit
 		}
 
 		if m.state == "confirm_delete" {
+			if key == "y" {
+				if m.opts.dryRun {
+					m.confirmDelete = false
+					return m, m.writeDeletePlan(false)
+				}
+				// Deletes that can't be undone are confirmed twice
+				if m.permanentDeletes() > 0 {
+					m.forceDelete = false
+					m.state = "confirm_force_delete"
+					return m, nil
+				}
+				m.confirmDelete = false
+				return m.startDelete(false)
+			} else if key == "f" {
+				m.forceDelete = true
+				m.state = "confirm_force_delete"
+			} else if key == "n" || key == "esc" {
+				m.confirmDelete = false
+				m.state = "results"
+				m.deleteError = ""
+			}
+			return m, nil
+		}
+
+		if m.state == "confirm_force_delete" {
 			if key == "y" {
 				m.confirmDelete = false
-				return m, m.performDelete()
+				if m.opts.dryRun {
+					return m, m.writeDeletePlan(m.forceDelete)
+				}
+				return m.startDelete(m.forceDelete)
 			} else if key == "n" || key == "esc" {
 				m.confirmDelete = false
 				m.state = "results"
@@ -410,16 +1292,146 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		if m.state == "deleting" {
+			return m, nil
+		}
+
+		if m.state == "stage_label" {
+			if key == "esc" {
+				m.state = "view_secret"
+				m.viewError = ""
+				return m, nil
+			}
+			if key == "enter" {
+				label := strings.TrimSpace(m.stageInput.Value())
+				if label == "" {
+					return m, nil
+				}
+				change, err := m.planStageChange(label, m.versionTable.Cursor(), m.stageDetach)
+				if err != nil {
+					m.viewError = fmt.Sprintf("Cannot change stages: %v", err)
+					return m, nil
+				}
+				m.viewError = ""
+				m.stageChange = change
+				m.state = "confirm_stage"
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.stageInput, cmd = m.stageInput.Update(msg)
+			return m, cmd
+		}
+
+		if m.state == "confirm_rotate" {
+			if m.rotating {
+				return m, nil
+			}
+			if key == "y" {
+				m.rotating = true
+				return m, m.rotateSecret(m.rotateTarget)
+			} else if key == "n" || key == "esc" {
+				m.state = "results"
+			}
+			return m, nil
+		}
+
+		if m.state == "confirm_stage" {
+			if m.stageSaving {
+				return m, nil
+			}
+			if key == "y" {
+				m.stageSaving = true
+				return m, m.updateStage(m.stageChange)
+			} else if key == "n" || key == "esc" {
+				m.state = "view_secret"
+			}
+			return m, nil
+		}
+
+		if m.state == "edit_secret" {
+			if m.editSaving {
+				return m, nil
+			}
+			switch key {
+			case "esc":
+				m.editError = ""
+				if m.editCreate {
+					m.state = "results"
+				} else {
+					m.state = "view_secret"
+				}
+				return m, nil
+			case "tab", "shift+tab":
+				fields := []int{editFocusName, editFocusValue}
+				if !m.editCreate {
+					fields = []int{editFocusValue, editFocusStages}
+				}
+				next := 0
+				for i, field := range fields {
+					if field == m.editFocus {
+						next = i + 1
+						if key == "shift+tab" {
+							next = i - 1 + len(fields)
+						}
+					}
+				}
+				m.focusEditor(fields[next%len(fields)])
+				return m, nil
+			case "ctrl+s":
+				return m.saveSecret()
+			}
+			var cmd tea.Cmd
+			switch m.editFocus {
+			case editFocusName:
+				m.editName, cmd = m.editName.Update(msg)
+			case editFocusStages:
+				m.editStages, cmd = m.editStages.Update(msg)
+			default:
+				m.editor, cmd = m.editor.Update(msg)
+			}
+			return m, cmd
+		}
+
+		if m.state == "delete_report" {
+			if key == "esc" || key == "enter" {
+				m.state = "results"
+				m.deleteOutcomes = nil
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.deleteTable, cmd = m.deleteTable.Update(msg)
+			return m, cmd
+		}
+
+		if m.state == "pending" {
+			if key == "esc" {
+				m.state = "results"
+				m.pending = nil
+				m.pendingMessage = ""
+				m.pendingLoading = false
+				return m, nil
+			}
+			if key == "u" {
+				cursor := m.pendingTable.Cursor()
+				if cursor >= 0 && cursor < len(m.pending) {
+					return m, m.restoreSecret(m.pending[cursor])
+				}
+			}
+			var cmd tea.Cmd
+			m.pendingTable, cmd = m.pendingTable.Update(msg)
+			return m, cmd
+		}
+
 		if m.state == "filter_include" || m.state == "filter_exclude" {
 			var cmd tea.Cmd
 			m.filterInput, cmd = m.filterInput.Update(msg)
 
-			filter := m.filterInput.Value()
+			filter := parseFilter(m.filterInput.Value())
 
 			// Preview filter
 			var tempResults []SecretResult
 			for _, res := range m.originalResults {
-				match := isFuzzyMatch(filter, res.Name)
+				match := filter.Match(res)
 				if (m.state == "filter_include" && match) || (m.state == "filter_exclude" && !match) {
 					tempResults = append(tempResults, res)
 				}
@@ -429,13 +1441,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.table.SetRows(m.formatResults())
 
 			if key == "esc" {
-				m.results = m.originalResults
-				m.selected = m.originalSelected
+				m.results = append([]SecretResult(nil), m.originalResults...)
+				m.selected = append([]bool(nil), m.originalSelected...)
 				m.table.SetRows(m.formatResults())
 				m.state = "results"
 				return m, nil
 			}
 			if key == "enter" {
+				// Remembered so results still streaming in are filtered too
+				m.filters = append(m.filters, appliedFilter{
+					filter:  filter,
+					include: m.state == "filter_include",
+				})
 				m.hasFilter = true
 				m.state = "results"
 				return m, nil
@@ -446,11 +1463,51 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.state == "view_secret" {
 			if key == "esc" {
 				m.state = "results"
-				m.viewingSecret = ""
+				m.viewing = SecretResult{}
+				m.viewError = ""
+				m.writeMessage = ""
 				m.versions = nil
 				m.table.SetCursor(m.lastCursorPos)
 				return m, nil
 			}
+			if key == "enter" {
+				cursor := m.versionTable.Cursor()
+				if cursor >= 0 && cursor < len(m.versions) {
+					m.viewError = ""
+					m.state = "secret_detail"
+					m.detailFields = nil
+					m.detailLoading = true
+					m.detailTable.SetRows(nil)
+					return m, m.fetchDetail(cursor)
+				}
+			}
+			if key == "m" {
+				cursor := m.versionTable.Cursor()
+				if cursor >= 0 && cursor < len(m.versions) {
+					if !m.versions[cursor].Marked && len(m.markedVersions()) == 2 {
+						m.viewError = "Only two versions can be compared; unmark one first"
+						return m, nil
+					}
+					m.viewError = ""
+					m.versions[cursor].Marked = !m.versions[cursor].Marked
+					m.versionTable.SetRows(m.formatVersions())
+					m.versionTable.SetCursor(cursor)
+				}
+				return m, nil
+			}
+			if key == "d" {
+				marked := m.markedVersions()
+				if len(marked) != 2 {
+					m.viewError = "Mark two versions with m to compare them"
+					return m, nil
+				}
+				m.viewError = ""
+				m.state = "diff_versions"
+				m.diff = nil
+				m.diffLoading = true
+				m.diffRevealed = false
+				return m, m.fetchDiff(marked[0], marked[1])
+			}
 			if key == "r" {
 				cursor := m.versionTable.Cursor()
 				if cursor >= 0 && cursor < len(m.versions) && !m.versions[cursor].Revealed {
@@ -458,12 +1515,60 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 			if key == "y" {
-				err := clipboard.WriteAll(m.viewingSecret)
-				if err == nil {
-					m.copiedMessage = "Copied secret name to clipboard"
-					return m, tea.Tick(time.Second*2, func(t time.Time) tea.Msg {
-						return clearCopiedMsg{}
-					})
+				return m, m.copyName(m.viewing)
+			}
+			if key == "P" || key == "l" || key == "u" {
+				cursor := m.versionTable.Cursor()
+				if cursor < 0 || cursor >= len(m.versions) {
+					return m, nil
+				}
+				if _, ok := m.viewing.store.(StageUpdater); !ok {
+					m.viewError = "This store does not support stage labels"
+					return m, nil
+				}
+				m.writeMessage = ""
+				if key == "P" {
+					change, err := m.planStageChange("AWSCURRENT", cursor, false)
+					if err != nil {
+						m.viewError = fmt.Sprintf("Cannot change stages: %v", err)
+						return m, nil
+					}
+					m.viewError = ""
+					m.stageChange = change
+					m.state = "confirm_stage"
+					return m, nil
+				}
+				m.viewError = ""
+				m.stageDetach = key == "u"
+				m.stageInput.Reset()
+				m.stageInput.Focus()
+				m.state = "stage_label"
+				return m, nil
+			}
+			if key == "e" {
+				if _, ok := m.viewing.store.(SecretWriter); !ok {
+					m.viewError = "This store does not support writing secrets"
+					return m, nil
+				}
+				// Start from the highlighted version if it has been revealed
+				value := ""
+				cursor := m.versionTable.Cursor()
+				if cursor >= 0 && cursor < len(m.versions) && m.versions[cursor].Revealed && !m.versions[cursor].binary {
+					value = m.versions[cursor].raw
+				}
+				m.viewError = ""
+				m.openEditor(false, m.viewing, value)
+				return m, nil
+			}
+			if key == "c" {
+				cursor := m.versionTable.Cursor()
+				if cursor >= 0 && cursor < len(m.versions) {
+					if !m.versions[cursor].Revealed {
+						m.viewError = "Reveal the value with r before copying it"
+						return m, nil
+					}
+					m.viewError = ""
+					return m, m.copyValue(cursor)
 				}
 			}
 			var cmd tea.Cmd
@@ -471,8 +1576,125 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 		}
 
+		if m.state == "secret_detail" {
+			if key == "esc" {
+				m.state = "view_secret"
+				m.detailFields = nil
+				m.detailTable.SetRows(nil)
+				return m, nil
+			}
+			cursor := m.detailTable.Cursor()
+			if key == "r" && cursor >= 0 && cursor < len(m.detailFields) {
+				if m.detailFields[cursor].Revealed {
+					m.detailFields[cursor].Revealed = false
+					m.detailTable.SetRows(m.formatDetail())
+					return m, nil
+				}
+				return m, m.revealField(cursor)
+			}
+			if key == "c" && cursor >= 0 && cursor < len(m.detailFields) {
+				return m, m.copyField(cursor)
+			}
+			var cmd tea.Cmd
+			m.detailTable, cmd = m.detailTable.Update(msg)
+			return m, cmd
+		}
+
+		if m.state == "diff_versions" {
+			if key == "esc" {
+				m.state = "view_secret"
+				m.diff = nil
+				m.diffRevealed = false
+				return m, nil
+			}
+			if key == "r" && !m.diffLoading && m.diff != nil {
+				if m.diffRevealed {
+					m.diffRevealed = false
+					return m, nil
+				}
+				return m, m.revealDiff()
+			}
+			return m, nil
+		}
+
+		if m.state == "excluded" {
+			if key == "esc" {
+				m.state = "results"
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.excludedTable, cmd = m.excludedTable.Update(msg)
+			return m, cmd
+		}
+
 		if m.state == "results" {
-			if key == "r" {
+			if key == "t" || key == "o" {
+				if key == "t" {
+					m.showTags = !m.showTags
+				} else {
+					m.showRotation = !m.showRotation
+				}
+				cursor := m.table.Cursor()
+				// Clear the rows first; the table renders them against the new columns
+				m.table.SetRows(nil)
+				m.table.SetColumns(resultColumns(m.showTags, m.showRotation, m.showRefs, m.showWorkloads))
+				m.table.SetRows(m.formatResults())
+				m.table.SetCursor(cursor)
+				return m, nil
+			}
+			if key == "s" {
+				m.sortBySeverity = !m.sortBySeverity
+				m.refreshResults()
+				return m, nil
+			}
+			if key == "S" {
+				// Cycle through the severities, then back to showing all
+				switch {
+				case !m.severityFilter:
+					m.severityFilter = true
+					m.minSeverity = SeverityInfo
+				case m.minSeverity == SeverityCritical:
+					m.severityFilter = false
+				default:
+					m.minSeverity++
+				}
+				m.refreshResults()
+				return m, nil
+			}
+			if key == "O" {
+				cursor := m.table.Cursor()
+				if cursor < 0 || cursor >= len(m.results) {
+					return m, nil
+				}
+				result := m.results[cursor]
+				if _, ok := result.store.(SecretRotator); !ok {
+					m.writeMessage = "This store does not support rotation"
+					return m, nil
+				}
+				if !result.RotationEnabled {
+					m.writeMessage = fmt.Sprintf("Rotation is not enabled for %s", result.Name)
+					return m, nil
+				}
+				m.writeMessage = ""
+				m.rotateTarget = result
+				m.state = "confirm_rotate"
+				return m, nil
+			}
+			if key == "p" {
+				m.state = "pending"
+				m.pending = nil
+				m.pendingMessage = ""
+				m.pendingLoading = true
+				m.pendingTable.SetRows(nil)
+				return m, m.fetchPending()
+			}
+			if key == "x" {
+				m.state = "excluded"
+				m.excludedTable.SetRows(m.formatExcluded())
+				m.excludedTable.SetCursor(0)
+				return m, nil
+			}
+			if key == "r" && !m.scanning {
 				m.filtered = true
 				m.state = "banner"
 				m.scanning = false
@@ -480,7 +1702,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return startScanMsg{}
 				})
 			}
-			if key == "R" {
+			if key == "R" && !m.scanning {
 				m.filtered = false
 				m.state = "banner"
 				m.scanning = false
@@ -492,7 +1714,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cursor := m.table.Cursor()
 				if cursor >= 0 && cursor < len(m.results) {
 					m.lastCursorPos = cursor
-					m.viewingSecret = m.results[cursor].Name
+					m.viewing = m.results[cursor]
 					m.state = "view_secret"
 					return m, m.fetchVersions()
 				}
@@ -527,15 +1749,25 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if key == "y" {
 				cursor := m.table.Cursor()
 				if cursor >= 0 && cursor < len(m.results) {
-					err := clipboard.WriteAll(m.results[cursor].Name)
-					if err == nil {
-						m.copiedMessage = "Copied secret name to clipboard"
-						return m, tea.Tick(time.Second*2, func(t time.Time) tea.Msg {
-							return clearCopiedMsg{}
-						})
-					}
+					return m, m.copyName(m.results[cursor])
 				}
 			}
+			if key == "n" {
+				// New secrets go to the highlighted secret's store
+				var store SecretStore
+				if cursor := m.table.Cursor(); cursor >= 0 && cursor < len(m.results) {
+					store = m.results[cursor].store
+				} else if len(m.analyzer.stores) > 0 {
+					store = m.analyzer.stores[0]
+				}
+				if _, ok := store.(SecretWriter); !ok {
+					m.writeMessage = "This store does not support creating secrets"
+					return m, nil
+				}
+				account, region := storeLocation(store)
+				m.openEditor(true, SecretResult{Account: account, Region: region, store: store}, "")
+				return m, nil
+			}
 			if key == "/" {
 				m.state = "filter_include"
 				m.originalResults = append([]SecretResult(nil), m.results...)
@@ -554,10 +1786,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			if key == "esc" {
 				if m.hasFilter {
-					m.results = m.baseResults
-					m.selected = make([]bool, len(m.results))
-					m.table.SetRows(m.formatResults())
 					m.hasFilter = false
+					m.filters = nil
+					m.refreshResults()
 				}
 			}
 			var cmd tea.Cmd
@@ -573,67 +1804,325 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.state = "scanning"
 		m.scanning = true
 		m.currentScanStep = "Connecting to AWS and analyzing secrets..."
+		m.scanProgress = ScanProgress{Stores: len(m.analyzer.stores)}
+		m.err = nil
+		m.writeMessage = ""
+		m.results = nil
+		m.baseResults = nil
+		m.selected = nil
+		m.excluded = nil
+		m.filters = nil
+		m.hasFilter = false
+		m.table.SetRows(nil)
 		return m, tea.Batch(
 			m.spinner.Tick,
+			m.progress.SetPercent(0),
 			m.startRealScan(),
 		)
 
+	case scanPageMsg:
+		m.scanProgress = msg.page.Progress
+		m.currentScanStep = describeScanProgress(msg.page.Progress)
+		m.excluded = append(m.excluded, msg.page.Excluded...)
+		filtering := m.state == "filter_include" || m.state == "filter_exclude"
+		preview := appliedFilter{
+			filter:  parseFilter(m.filterInput.Value()),
+			include: m.state == "filter_include",
+		}
+		for _, result := range msg.page.Results {
+			m.baseResults = append(m.baseResults, result)
+			if !m.keep(result) {
+				continue
+			}
+			if filtering {
+				m.originalResults = append(m.originalResults, result)
+				m.originalSelected = append(m.originalSelected, false)
+				if !preview.keep(result) {
+					continue
+				}
+			}
+			m.results = append(m.results, result)
+			m.selected = append(m.selected, false)
+		}
+		for _, traced := range msg.page.Traced {
+			mergeTraced(m.baseResults, traced)
+			mergeTraced(m.originalResults, traced)
+			mergeTraced(m.results, traced)
+			if resultKey(m.viewing) == resultKey(traced) {
+				m.viewing.LastRead, m.viewing.Findings = traced.LastRead, traced.Findings
+			}
+		}
+		if len(msg.page.Traced) > 0 {
+			m.table.SetRows(m.formatResults())
+		}
+		if len(msg.page.Results) > 0 {
+			m.sortResults()
+			m.table.SetRows(m.formatResults())
+			// Let the user browse as soon as there is something to see
+			if m.state == "scanning" {
+				m.state = "results"
+			}
+		}
+		return m, tea.Batch(
+			m.progress.SetPercent(msg.page.Progress.Fraction()),
+			waitForScan(msg.updates),
+		)
+
 	case analysisCompleteMsg:
 		m.scanning = false
-		m.state = "results"
-		m.baseResults = msg.results
-		m.results = msg.results
-		m.err = msg.err
-		if m.err == nil {
-			m.selected = make([]bool, len(m.results))
-			m.table.SetRows(m.formatResults())
-			m.hasFilter = false
+		if m.state == "scanning" {
+			m.state = "results"
 		}
+		m.err = msg.err
 		return m, nil
 
 	case versionsFetchedMsg:
 		if msg.err != nil {
-			m.err = msg.err
-			m.state = "results"
+			m.viewError = fmt.Sprintf("Failed to list versions: %v", msg.err)
 		} else {
 			m.versions = msg.versions
 			m.versionTable.SetRows(m.formatVersions())
 		}
 		return m, nil
 
+	case diffFetchedMsg:
+		m.diffLoading = false
+		if msg.err != nil {
+			m.viewError = fmt.Sprintf("Diff failed: %v", msg.err)
+			m.state = "view_secret"
+			return m, nil
+		}
+		m.diff = msg.lines
+		m.diffJSON = msg.isJSON
+		m.diffFrom = msg.from
+		m.diffTo = msg.to
+		return m, nil
+
+	case detailFetchedMsg:
+		m.detailLoading = false
+		if msg.err != nil {
+			m.viewError = fmt.Sprintf("Failed to read value: %v", msg.err)
+			m.state = "view_secret"
+			return m, nil
+		}
+		m.detailVersion = msg.version
+		m.detailJSON = msg.isJSON
+		m.detailFields = nil
+		for _, field := range msg.fields {
+			m.detailFields = append(m.detailFields, detailField{secretField: field})
+		}
+		m.detailTable.SetRows(m.formatDetail())
+		m.detailTable.SetCursor(0)
+		return m, nil
+
+	case fieldRevealedMsg:
+		if msg.err != nil {
+			m.viewError = fmt.Sprintf("Reveal failed: %v", msg.err)
+		} else if msg.index < len(m.detailFields) {
+			m.viewError = ""
+			m.detailFields[msg.index].Revealed = true
+			m.detailTable.SetRows(m.formatDetail())
+		}
+		return m, nil
+
+	case diffRevealedMsg:
+		if msg.err != nil {
+			m.viewError = fmt.Sprintf("Reveal failed: %v", msg.err)
+			m.state = "view_secret"
+			m.diff = nil
+			return m, nil
+		}
+		m.diffRevealed = true
+		return m, nil
+
 	case valueRevealedMsg:
 		if msg.err != nil {
-			m.err = msg.err
+			m.viewError = fmt.Sprintf("Reveal failed: %v", msg.err)
 		} else {
+			m.viewError = ""
 			m.versions[msg.index].Value = msg.value
+			m.versions[msg.index].raw = msg.raw
+			m.versions[msg.index].binary = msg.binary
 			m.versions[msg.index].Revealed = true
 			m.versionTable.SetRows(m.formatVersions())
 		}
 		return m, nil
 
+	case secretWrittenMsg:
+		m.editSaving = false
+		if msg.err != nil {
+			m.editError = msg.err.Error()
+			return m, nil
+		}
+		m.editError = ""
+		if msg.created {
+			m.state = "results"
+			m.writeMessage = fmt.Sprintf("Created secret %s; rescan to see it in the results", msg.result.Name)
+			return m, nil
+		}
+		m.state = "view_secret"
+		m.writeMessage = fmt.Sprintf("Added version %s to %s", msg.version.VersionId, msg.result.Name)
+		return m, m.fetchVersions()
+
+	case rotateStartedMsg:
+		m.rotating = false
+		m.state = "results"
+		if msg.err != nil {
+			m.writeMessage = fmt.Sprintf("Rotation failed: %v", msg.err)
+		} else {
+			m.writeMessage = fmt.Sprintf("Started rotation of %s; rescan to see the new version", msg.result.Name)
+		}
+		return m, nil
+
+	case stageUpdatedMsg:
+		m.stageSaving = false
+		m.state = "view_secret"
+		if msg.err != nil {
+			m.viewError = fmt.Sprintf("Stage update failed: %v", msg.err)
+			return m, m.fetchVersions()
+		}
+		m.writeMessage = msg.change.done()
+		return m, m.fetchVersions()
+
+	case deleteProgressMsg:
+		m.deleteOutcomes = append(m.deleteOutcomes, msg.outcome)
+		cmd := m.progress.SetPercent(float64(len(m.deleteOutcomes)) / float64(m.deleteTotal))
+		return m, tea.Batch(cmd, waitForDelete(msg.outcomes))
+
 	case deleteCompleteMsg:
+		// Drop the secrets that were deleted; failed ones stay selected so
+		// they can be retried
+		deleted := map[string]bool{}
+		for _, outcome := range m.deleteOutcomes {
+			if outcome.err == nil {
+				deleted[resultKey(outcome.result)] = true
+			}
+		}
+		var newResults []SecretResult
+		var newSelected []bool
+		for i, result := range m.results {
+			if !deleted[resultKey(result)] {
+				newResults = append(newResults, result)
+				newSelected = append(newSelected, m.selected[i])
+			}
+		}
+		var newBase []SecretResult
+		for _, result := range m.baseResults {
+			if !deleted[resultKey(result)] {
+				newBase = append(newBase, result)
+			}
+		}
+		m.results = newResults
+		m.baseResults = newBase
+		m.selected = newSelected
+		m.table.SetRows(m.formatResults())
+		m.table.SetCursor(0)
+
+		sort.Slice(m.deleteOutcomes, func(i, j int) bool {
+			return m.deleteOutcomes[i].index < m.deleteOutcomes[j].index
+		})
+		m.deleteTable.SetRows(m.formatDeleteOutcomes())
+		m.deleteTable.SetCursor(0)
+		m.state = "delete_report"
+		return m, nil
+
+	case planWrittenMsg:
 		if msg.err != nil {
 			m.deleteError = msg.err.Error()
 		} else {
-			// Remove deleted secrets from results
-			var newResults []SecretResult
-			var newSelected []bool
-			for i, sel := range m.selected {
-				if !sel {
-					newResults = append(newResults, m.results[i])
-					newSelected = append(newSelected, false)
-				}
-			}
-			m.results = newResults
-			m.baseResults = newResults // Update base if deleted
-			m.selected = newSelected
-			m.table.SetRows(m.formatResults())
+			m.deleteError = ""
+			m.planMessage = fmt.Sprintf("Dry run: wrote deletion plan to %s; run `sniffy apply %s` once it is reviewed", msg.path, msg.path)
 		}
 		m.state = "results"
 		return m, nil
 
+	case pendingFetchedMsg:
+		m.pendingLoading = false
+		if msg.err != nil {
+			m.pendingMessage = fmt.Sprintf("Error: %v", msg.err)
+		} else {
+			m.pending = msg.pending
+			m.pendingTable.SetRows(m.formatPending())
+			m.pendingTable.SetCursor(0)
+		}
+		return m, nil
+
+	case restoreCompleteMsg:
+		if msg.err != nil {
+			m.pendingMessage = fmt.Sprintf("Error: %v", msg.err)
+			return m, nil
+		}
+		m.pendingMessage = fmt.Sprintf("Restored %s; rescan to see it in the results", msg.name)
+		return m, m.fetchPending()
+
+	case copiedMsg:
+		m.copySeq++
+		seq := m.copySeq
+		if msg.err != nil {
+			m.copiedMessage = fmt.Sprintf("Copy failed: %v", msg.err)
+			if m.clipboardValue != "" {
+				// The clipboard may still hold an earlier copy; keep its
+				// deadline under the new sequence number
+				return m, tea.Batch(
+					tea.Tick(time.Second, func(t time.Time) tea.Msg {
+						return clipboardTickMsg{seq: seq}
+					}),
+					tea.Tick(time.Second*2, func(t time.Time) tea.Msg {
+						return clearCopiedMsg{seq: seq}
+					}),
+				)
+			}
+		} else {
+			m.copiedMessage = msg.message
+			m.clipboardValue = ""
+			if timeout := m.analyzer.config.ClipboardClearSeconds; msg.value != "" && timeout > 0 {
+				m.clipboardValue = msg.value
+				m.clipboardClearAt = time.Now().Add(time.Duration(timeout) * time.Second)
+				return m, tea.Tick(time.Second, func(t time.Time) tea.Msg {
+					return clipboardTickMsg{seq: seq}
+				})
+			}
+		}
+		return m, tea.Tick(time.Second*2, func(t time.Time) tea.Msg {
+			return clearCopiedMsg{seq: seq}
+		})
+
+	case clipboardTickMsg:
+		if msg.seq != m.copySeq || m.clipboardValue == "" {
+			return m, nil
+		}
+		if time.Now().Before(m.clipboardClearAt) {
+			return m, tea.Tick(time.Second, func(t time.Time) tea.Msg {
+				return clipboardTickMsg{seq: msg.seq}
+			})
+		}
+		value := m.clipboardValue
+		m.clipboardValue = ""
+		return m, func() tea.Msg {
+			cleared, err := clearClipboard(value)
+			return clipboardClearedMsg{seq: msg.seq, cleared: cleared, err: err}
+		}
+
+	case clipboardClearedMsg:
+		if msg.seq != m.copySeq {
+			return m, nil
+		}
+		switch {
+		case msg.err != nil:
+			m.copiedMessage = fmt.Sprintf("Failed to clear clipboard: %v", msg.err)
+		case msg.cleared:
+			m.copiedMessage = "Clipboard cleared"
+		default:
+			m.copiedMessage = "Clipboard no longer holds the copied value; left it alone"
+		}
+		return m, tea.Tick(time.Second*2, func(t time.Time) tea.Msg {
+			return clearCopiedMsg{seq: msg.seq}
+		})
+
 	case clearCopiedMsg:
-		m.copiedMessage = ""
+		if msg.seq == m.copySeq {
+			m.copiedMessage = ""
+		}
 		return m, nil
 
 	case spinner.TickMsg:
@@ -641,83 +2130,587 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
 
-	case progress.FrameMsg:
-		progressModel, cmd := m.progress.Update(msg)
-		m.progress = progressModel.(progress.Model)
-		return m, cmd
+	case progress.FrameMsg:
+		progressModel, cmd := m.progress.Update(msg)
+		m.progress = progressModel.(progress.Model)
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+func (m model) startRealScan() tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		updates := make(chan tea.Msg)
+		go func() {
+			err := m.analyzer.StreamSecrets(ctx, m.filtered, func(page ScanPage) {
+				updates <- scanPageMsg{page: page, updates: updates}
+			})
+			updates <- analysisCompleteMsg{err: err}
+			close(updates)
+		}()
+		return <-updates
+	}
+}
+
+func waitForScan(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+// keep reports whether a result passes every filter the user applied
+func (m model) keep(result SecretResult) bool {
+	if m.severityFilter && !result.hasFindingAtLeast(m.minSeverity) {
+		return false
+	}
+	for _, f := range m.filters {
+		if !f.keep(result) {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshResults rebuilds the visible results from everything scanned,
+// keeping the selection of secrets that stay visible
+func (m *model) refreshResults() {
+	selected := map[string]bool{}
+	for i, result := range m.results {
+		if i < len(m.selected) && m.selected[i] {
+			selected[resultKey(result)] = true
+		}
+	}
+	m.results = nil
+	m.selected = nil
+	for _, result := range m.baseResults {
+		if m.keep(result) {
+			m.results = append(m.results, result)
+			m.selected = append(m.selected, selected[resultKey(result)])
+		}
+	}
+	m.sortResults()
+	m.table.SetRows(m.formatResults())
+	m.table.SetCursor(0)
+}
+
+// sortResults puts the most severe findings first when sorting by severity;
+// otherwise results stay in scan order
+func (m *model) sortResults() {
+	if !m.sortBySeverity {
+		return
+	}
+	rank := func(result SecretResult) int {
+		severity, ok := result.MaxSeverity()
+		if !ok {
+			return -1
+		}
+		return int(severity)
+	}
+	order := make([]int, len(m.results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := m.results[order[a]], m.results[order[b]]
+		if rank(ra) != rank(rb) {
+			return rank(ra) > rank(rb)
+		}
+		return len(ra.Findings) > len(rb.Findings)
+	})
+	results := make([]SecretResult, len(order))
+	selected := make([]bool, len(order))
+	for i, j := range order {
+		results[i] = m.results[j]
+		selected[i] = j < len(m.selected) && m.selected[j]
+	}
+	m.results = results
+	m.selected = selected
+}
+
+func describeScanProgress(p ScanProgress) string {
+	step := fmt.Sprintf("Fetched %d pages, %d secrets, %d analyzed", p.Pages, p.Listed, p.Analyzed)
+	if p.Stores > 1 {
+		step += fmt.Sprintf(" • %d of %d locations done", p.StoresDone, p.Stores)
+	}
+	if p.Account != "" {
+		step += fmt.Sprintf(" • latest from %s in %s", p.Account, p.Region)
+	}
+	if p.ToTrace > 0 {
+		step += fmt.Sprintf(" • looked up last reads of %d of %d secrets in CloudTrail", p.Traced, p.ToTrace)
+	}
+	return step
+}
+
+func (m model) fetchVersions() tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		versionsRaw, err := m.viewing.store.ListSecretVersions(ctx, m.viewing.Name)
+		if err != nil {
+			return versionsFetchedMsg{err: err}
+		}
+
+		var versions []VersionInfo
+		for _, v := range versionsRaw {
+			createdStr := ""
+			var created time.Time
+			if v.CreatedDate != nil {
+				created = *v.CreatedDate
+				createdStr = created.Format("2006-01-02 15:04")
+			}
+			lastAccessedStr := "Never"
+			if v.LastAccessedDate != nil {
+				lastAccessedStr = v.LastAccessedDate.Format("2006-01-02")
+			}
+			stagesStr := strings.Join(v.Stages, ", ")
+			versions = append(versions, VersionInfo{
+				VersionId:    v.VersionId,
+				CreatedDate:  createdStr,
+				LastAccessed: lastAccessedStr,
+				Stages:       stagesStr,
+				Value:        "********",
+				Revealed:     false,
+				stages:       v.Stages,
+				created:      created,
+			})
+		}
+
+		return versionsFetchedMsg{versions: versions}
+	}
+}
+
+func (m model) revealValue(index int) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		versionId := m.versions[index].VersionId
+		value, err := m.viewing.store.GetSecretValue(ctx, m.viewing.Name, versionId)
+		// Never show a value whose reveal could not be recorded
+		if auditErr := m.audit.Record(newAuditEvent(ctx, auditReveal, m.viewing, versionId, err)); auditErr != nil {
+			return valueRevealedMsg{index: index, err: auditErr}
+		}
+		if err != nil {
+			return valueRevealedMsg{index: index, err: err}
+		}
+		display := value.Text()
+		if value.Binary != nil {
+			display = fmt.Sprintf("(binary, %d bytes) %s", len(value.Binary), display)
+		}
+		return valueRevealedMsg{index: index, value: display, raw: value.Text(), binary: value.Binary != nil}
+	}
+}
+
+// planStageChange works out how to attach stage to, or detach it from, the
+// version at index. A stage is on at most one version, so attaching it moves
+// it from the version that has it.
+func (m model) planStageChange(stage string, index int, detach bool) (stageChange, error) {
+	if index < 0 || index >= len(m.versions) {
+		return stageChange{}, errors.New("no version selected")
+	}
+	version := m.versions[index]
+	if detach {
+		if !slices.Contains(version.stages, stage) {
+			return stageChange{}, fmt.Errorf("version %s has no %s stage", version.VersionId, stage)
+		}
+		if stage == "AWSCURRENT" {
+			return stageChange{}, errors.New("AWSCURRENT can't be detached; make another version current with P")
+		}
+		return stageChange{stage: stage, removeFrom: version.VersionId}, nil
+	}
+
+	change := stageChange{stage: stage, moveTo: version.VersionId}
+	for _, v := range m.versions {
+		if slices.Contains(v.stages, stage) {
+			if v.VersionId == version.VersionId {
+				return stageChange{}, fmt.Errorf("version %s already has %s", version.VersionId, stage)
+			}
+			change.removeFrom = v.VersionId
+		}
+	}
+	return change, nil
+}
+
+// updateStage applies a confirmed stage change and records it in the audit
+// log
+func (m model) updateStage(change stageChange) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		updater := m.viewing.store.(StageUpdater)
+		err := updater.UpdateVersionStage(ctx, m.viewing.Name, change.stage, change.moveTo, change.removeFrom)
+
+		action, versionId := auditAttach, change.moveTo
+		if change.moveTo == "" {
+			action, versionId = auditDetach, change.removeFrom
+		}
+		event := newAuditEvent(ctx, action, m.viewing, versionId, err)
+		event.Stage = change.stage
+		if auditErr := m.audit.Record(event); auditErr != nil {
+			err = errors.Join(err, auditErr)
+		}
+		return stageUpdatedMsg{change: change, err: err}
+	}
+}
+
+// rotateSecret starts a rotation and records it in the audit log
+func (m model) rotateSecret(result SecretResult) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		err := result.store.(SecretRotator).RotateSecret(ctx, result.Name)
+		if auditErr := m.audit.Record(newAuditEvent(ctx, auditRotate, result, "", err)); auditErr != nil {
+			err = errors.Join(err, auditErr)
+		}
+		return rotateStartedMsg{result: result, err: err}
+	}
+}
+
+// openEditor shows the secret editor, either for a new secret in target's
+// store or for a new version of target
+func (m *model) openEditor(create bool, target SecretResult, value string) {
+	m.state = "edit_secret"
+	m.editCreate = create
+	m.editTarget = target
+	m.editError = ""
+	m.writeMessage = ""
+	m.editName.Reset()
+	m.editStages.SetValue("AWSCURRENT")
+	m.editor.Reset()
+	m.editor.SetValue(value)
+	if create {
+		m.focusEditor(editFocusName)
+	} else {
+		m.focusEditor(editFocusValue)
+	}
+}
+
+func (m *model) focusEditor(field int) {
+	m.editFocus = field
+	m.editName.Blur()
+	m.editStages.Blur()
+	m.editor.Blur()
+	switch field {
+	case editFocusName:
+		m.editName.Focus()
+	case editFocusStages:
+		m.editStages.Focus()
+	default:
+		m.editor.Focus()
+	}
+}
+
+// saveSecret validates the editor and writes the secret
+func (m model) saveSecret() (tea.Model, tea.Cmd) {
+	target := m.editTarget
+	if m.editCreate {
+		target.Name = strings.TrimSpace(m.editName.Value())
+		if target.Name == "" {
+			m.editError = "Enter a name for the secret"
+			return m, nil
+		}
+	}
+	value := m.editor.Value()
+	if _, err := validateSecretValue(value); err != nil {
+		m.editError = fmt.Sprintf("Cannot save: %v", err)
+		return m, nil
+	}
+	m.editError = ""
+	m.editSaving = true
+	return m, m.writeSecret(target, value, splitList(m.editStages.Value()))
+}
+
+// writeSecret creates a secret or adds a version to one, and records the
+// write in the audit log
+func (m model) writeSecret(result SecretResult, value string, stages []string) tea.Cmd {
+	create := m.editCreate
+	return func() tea.Msg {
+		ctx := context.Background()
+		writer := result.store.(SecretWriter)
+		action := auditPut
+		var version WrittenVersion
+		var err error
+		if create {
+			action = auditCreate
+			version, err = writer.CreateSecret(ctx, result.Name, value)
+		} else {
+			version, err = writer.PutSecretValue(ctx, result.Name, value, stages)
+		}
+		if version.ARN != "" {
+			result.ARN = version.ARN
+		}
+		if auditErr := m.audit.Record(newAuditEvent(ctx, action, result, version.VersionId, err)); auditErr != nil {
+			err = errors.Join(err, auditErr)
+		}
+		return secretWrittenMsg{result: result, version: version, created: create, err: err}
+	}
+}
+
+// markedVersions returns the indexes of the versions marked for a diff
+func (m model) markedVersions() []int {
+	var marked []int
+	for i, v := range m.versions {
+		if v.Marked {
+			marked = append(marked, i)
+		}
+	}
+	return marked
+}
+
+// fetchDiff reads two versions and compares the older with the newer. Both
+// reads are recorded in the audit log before anything is compared.
+func (m model) fetchDiff(a, b int) tea.Cmd {
+	from, to := m.versions[a], m.versions[b]
+	if to.created.Before(from.created) {
+		from, to = to, from
+	}
+	return func() tea.Msg {
+		ctx := context.Background()
+		values := make([]string, 2)
+		for i, v := range []VersionInfo{from, to} {
+			value, err := m.viewing.store.GetSecretValue(ctx, m.viewing.Name, v.VersionId)
+			if auditErr := m.audit.Record(newAuditEvent(ctx, auditDiff, m.viewing, v.VersionId, err)); auditErr != nil {
+				return diffFetchedMsg{err: auditErr}
+			}
+			if err != nil {
+				return diffFetchedMsg{err: err}
+			}
+			values[i] = value.Text()
+		}
+		lines, isJSON := diffSecretValues(values[0], values[1])
+		return diffFetchedMsg{from: from, to: to, lines: lines, isJSON: isJSON}
+	}
+}
+
+// revealDiff records that the values in the diff are about to be shown
+func (m model) revealDiff() tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		for _, v := range []VersionInfo{m.diffFrom, m.diffTo} {
+			if err := m.audit.Record(newAuditEvent(ctx, auditReveal, m.viewing, v.VersionId, nil)); err != nil {
+				return diffRevealedMsg{err: err}
+			}
+		}
+		return diffRevealedMsg{}
+	}
+}
+
+// copyName copies a secret's name to the clipboard once the copy has been
+// recorded
+func (m model) copyName(result SecretResult) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		err := m.audit.Record(newAuditEvent(ctx, auditCopy, result, "", nil))
+		if err == nil {
+			err = clipboard.WriteAll(result.Name)
+		}
+		return copiedMsg{message: "Copied secret name to clipboard", err: err}
+	}
+}
+
+// copyValue copies a revealed version's value to the clipboard once the copy
+// has been recorded
+func (m model) copyValue(index int) tea.Cmd {
+	version := m.versions[index]
+	return func() tea.Msg {
+		err := m.audit.Record(newAuditEvent(context.Background(), auditCopy, m.viewing, version.VersionId, nil))
+		if err == nil {
+			err = clipboard.WriteAll(version.raw)
+		}
+		message := fmt.Sprintf("Copied value of version %s to clipboard", version.VersionId)
+		return copiedMsg{message: message, value: version.raw, err: err}
+	}
+}
+
+// clearClipboard empties the clipboard if it still holds value, so anything
+// copied since is left alone
+func clearClipboard(value string) (bool, error) {
+	current, err := clipboard.ReadAll()
+	if err != nil {
+		return false, err
+	}
+	if current != value {
+		return false, nil
+	}
+	return true, clipboard.WriteAll("")
+}
+
+// fetchDetail reads a version's value and splits it into fields. Nothing is
+// shown until a field is revealed, so the read itself isn't audited.
+func (m model) fetchDetail(index int) tea.Cmd {
+	version := m.versions[index]
+	return func() tea.Msg {
+		ctx := context.Background()
+		value, err := m.viewing.store.GetSecretValue(ctx, m.viewing.Name, version.VersionId)
+		if err != nil {
+			return detailFetchedMsg{err: err}
+		}
+		fields, ok := value.Fields()
+		if !ok {
+			key := "(value)"
+			if value.Binary != nil {
+				key = fmt.Sprintf("(binary, %d bytes, base64)", len(value.Binary))
+			}
+			fields = []secretField{{Key: key, Value: value.Text()}}
+		}
+		return detailFetchedMsg{version: version, fields: fields, isJSON: ok}
+	}
+}
+
+// revealField records the reveal of one field before showing it
+func (m model) revealField(index int) tea.Cmd {
+	field := m.detailFields[index]
+	return func() tea.Msg {
+		event := newAuditEvent(context.Background(), auditReveal, m.viewing, m.detailVersion.VersionId, nil)
+		if m.detailJSON {
+			event.Key = field.Key
+		}
+		return fieldRevealedMsg{index: index, err: m.audit.Record(event)}
+	}
+}
+
+// copyField copies one field's value to the clipboard once the copy has been
+// recorded
+func (m model) copyField(index int) tea.Cmd {
+	field := m.detailFields[index]
+	return func() tea.Msg {
+		event := newAuditEvent(context.Background(), auditCopy, m.viewing, m.detailVersion.VersionId, nil)
+		message := "Copied value to clipboard"
+		if m.detailJSON {
+			event.Key = field.Key
+			message = fmt.Sprintf("Copied %s to clipboard", field.Key)
+		}
+		err := m.audit.Record(event)
+		if err == nil {
+			err = clipboard.WriteAll(field.Value)
+		}
+		return copiedMsg{message: message, value: field.Value, err: err}
 	}
+}
 
-	return m, nil
+// startDelete deletes the selected secrets in the background and shows
+// progress until they are all done
+// permanentDeletes counts the selected secrets whose stores can't undo a
+// delete
+func (m model) permanentDeletes() int {
+	n := 0
+	for i, sel := range m.selected {
+		if sel && deletesPermanently(m.results[i].store) {
+			n++
+		}
+	}
+	return n
 }
 
-func (m model) startRealScan() tea.Cmd {
+func (m model) startDelete(force bool) (tea.Model, tea.Cmd) {
+	var secrets []SecretResult
+	for i, sel := range m.selected {
+		if sel {
+			secrets = append(secrets, m.results[i])
+		}
+	}
+	m.state = "deleting"
+	m.deleteError = ""
+	m.deleteTotal = len(secrets)
+	m.deleteOutcomes = nil
+	return m, tea.Batch(m.progress.SetPercent(0), m.performDelete(secrets, force))
+}
+
+func (m model) performDelete(secrets []SecretResult, force bool) tea.Cmd {
+	opts := DeleteOptions{
+		RecoveryWindowDays: m.analyzer.config.RecoveryWindowDays,
+		Force:              force,
+	}
 	return func() tea.Msg {
-		ctx := context.Background()
-		results, err := m.analyzer.AnalyzeSecrets(ctx, m.filtered)
-		return analysisCompleteMsg{results: results, err: err}
+		return waitForDelete(deleteSecrets(context.Background(), secrets, opts, m.audit))()
 	}
 }
 
-func (m model) fetchVersions() tea.Cmd {
+func waitForDelete(outcomes <-chan deleteOutcome) tea.Cmd {
 	return func() tea.Msg {
-		ctx := context.Background()
-		versionsRaw, err := m.analyzer.awsManager.ListSecretVersions(ctx, m.viewingSecret)
-		if err != nil {
-			return versionsFetchedMsg{err: err}
+		outcome, ok := <-outcomes
+		if !ok {
+			return deleteCompleteMsg{}
 		}
+		return deleteProgressMsg{outcome: outcome, outcomes: outcomes}
+	}
+}
 
-		var versions []VersionInfo
-		for _, v := range versionsRaw {
-			createdStr := ""
-			if v.CreatedDate != nil {
-				createdStr = v.CreatedDate.Format("2006-01-02 15:04")
-			}
-			lastAccessedStr := "Never"
-			if v.LastAccessedDate != nil {
-				lastAccessedStr = v.LastAccessedDate.Format("2006-01-02")
-			}
-			stagesStr := strings.Join(v.VersionStages, ", ")
-			versions = append(versions, VersionInfo{
-				VersionId:    *v.VersionId,
-				CreatedDate:  createdStr,
-				LastAccessed: lastAccessedStr,
-				Stages:       stagesStr,
-				Value:        "********",
-				Revealed:     false,
+// resultKey identifies a secret across result lists
+func resultKey(result SecretResult) string {
+	return result.Account + "/" + result.Region + "/" + result.Name
+}
+
+// writeDeletePlan records the selected secrets in a plan file instead of
+// deleting them
+func (m model) writeDeletePlan(force bool) tea.Cmd {
+	plan := DeletionPlan{
+		CreatedAt:          time.Now().UTC(),
+		Source:             m.opts.source,
+		RecoveryWindowDays: m.analyzer.config.RecoveryWindowDays,
+		Force:              force,
+	}
+	if m.opts.source == sourceVault {
+		plan.VaultMount, plan.VaultDestroy = m.opts.vaultMount, m.opts.vaultDestroy
+	}
+	for i, sel := range m.selected {
+		if sel {
+			result := m.results[i]
+			plan.Secrets = append(plan.Secrets, PlanEntry{
+				Name:         result.Name,
+				ARN:          result.ARN,
+				Account:      result.Account,
+				Region:       result.Region,
+				LastAccessed: result.LastAccessed,
+				Tags:         result.Tags,
 			})
 		}
+	}
 
-		return versionsFetchedMsg{versions: versions}
+	path := m.opts.planFile
+	if path == "" {
+		path = defaultPlanPath()
+	}
+
+	return func() tea.Msg {
+		return planWrittenMsg{path: path, err: writePlan(path, plan)}
 	}
 }
 
-func (m model) revealValue(index int) tea.Cmd {
+func (m model) fetchPending() tea.Cmd {
 	return func() tea.Msg {
 		ctx := context.Background()
-		value, err := m.analyzer.awsManager.GetSecretValue(ctx, m.viewingSecret, m.versions[index].VersionId)
-		if err != nil {
-			return valueRevealedMsg{index: index, err: err}
+		var pending []pendingSecret
+		for _, store := range m.analyzer.stores {
+			restorer, ok := store.(SecretRestorer)
+			if !ok {
+				continue
+			}
+			entries, err := restorer.ListPendingDeletion(ctx)
+			if err != nil {
+				return pendingFetchedMsg{err: err}
+			}
+			for _, entry := range entries {
+				pending = append(pending, pendingSecret{entry: entry, store: restorer})
+			}
 		}
-		return valueRevealedMsg{index: index, value: value}
+		return pendingFetchedMsg{pending: pending}
 	}
 }
 
-func (m model) performDelete() tea.Cmd {
+func (m model) restoreSecret(p pendingSecret) tea.Cmd {
 	return func() tea.Msg {
 		ctx := context.Background()
-		var errStr strings.Builder
-		for i, sel := range m.selected {
-			if sel {
-				err := m.analyzer.awsManager.DeleteSecret(ctx, m.results[i].Name)
-				if err != nil {
-					errStr.WriteString(fmt.Sprintf("%s: %v\n", m.results[i].Name, err))
-				}
-			}
+		err := p.store.RestoreSecret(ctx, p.entry.Name)
+		store, _ := p.store.(SecretStore)
+		result := SecretResult{
+			Name:    p.entry.Name,
+			ARN:     p.entry.ARN,
+			Account: p.entry.Account,
+			Region:  p.entry.Region,
+			store:   store,
 		}
-		if errStr.Len() > 0 {
-			return deleteCompleteMsg{err: fmt.Errorf(errStr.String())}
+		if auditErr := m.audit.Record(newAuditEvent(ctx, auditRestore, result, "", err)); auditErr != nil && err == nil {
+			err = auditErr
 		}
-		return deleteCompleteMsg{}
+		return restoreCompleteMsg{name: p.entry.Name, err: err}
 	}
 }
 
@@ -742,7 +2735,7 @@ func (m model) View() string {
 		s.WriteString(m.renderScanningProgress())
 
 	case "results":
-		if m.err != nil {
+		if m.err != nil && len(m.baseResults) == 0 {
 			s.WriteString(errorStyle.Render(fmt.Sprintf("Scan failed: %v", m.err)))
 			s.WriteString("\n\n")
 			s.WriteString(dimStyle.Render("Check AWS credentials and permissions"))
@@ -754,18 +2747,211 @@ func (m model) View() string {
 		s.WriteString(m.renderResults())
 		s.WriteString("\n\n")
 		if m.state == "filter_include" {
-			s.WriteString("Include secrets containing: " + m.filterInput.View())
+			s.WriteString("Include secrets matching: " + m.filterInput.View())
 		} else {
-			s.WriteString("Exclude secrets containing: " + m.filterInput.View())
+			s.WriteString("Exclude secrets matching: " + m.filterInput.View())
 		}
 
 	case "view_secret":
-		s.WriteString(titleStyle.Render(fmt.Sprintf("Versions for %s", m.viewingSecret)))
+		s.WriteString(titleStyle.Render(fmt.Sprintf("Versions for %s", m.viewing.Name)))
 		s.WriteString("\n")
 		s.WriteString(m.versionTable.View())
+		if read := m.viewing.LastRead; read != nil {
+			s.WriteString("\n\n")
+			s.WriteString(uiStyle.Render("Last read"))
+			s.WriteString(fmt.Sprintf("\n  %s by %s from %s", read.Time.Local().Format("2006-01-02 15:04:05"), read.Principal, read.Source))
+			if read.UserAgent != "" {
+				s.WriteString("\n")
+				s.WriteString(dimStyle.Render("  " + read.UserAgent))
+			}
+			s.WriteString("\n")
+		}
+		if m.showRefs {
+			s.WriteString("\n\n")
+			s.WriteString(uiStyle.Render(fmt.Sprintf("References (%d)", len(m.viewing.References))))
+			refs := m.viewing.References
+			if len(refs) > maxShownReferences {
+				refs = refs[:maxShownReferences]
+			}
+			for _, ref := range refs {
+				s.WriteString("\n  " + ref.String())
+			}
+			if len(m.viewing.References) > len(refs) {
+				s.WriteString("\n")
+				s.WriteString(dimStyle.Render(fmt.Sprintf("  and %d more; run sniffy refs for all of them", len(m.viewing.References)-len(refs))))
+			}
+			s.WriteString("\n")
+		}
+		if m.showWorkloads {
+			s.WriteString("\n\n")
+			s.WriteString(uiStyle.Render(fmt.Sprintf("Workloads (%d)", len(m.viewing.Workloads))))
+			for _, workload := range m.viewing.Workloads {
+				s.WriteString("\n  " + workload.String())
+			}
+			s.WriteString("\n")
+		}
+		if len(m.viewing.Findings) > 0 {
+			s.WriteString("\n\n")
+			s.WriteString(uiStyle.Render("Findings"))
+			for _, finding := range m.viewing.Findings {
+				s.WriteString("\n")
+				s.WriteString(severityStyle(finding.Severity).Render(fmt.Sprintf("  %-8s %s: %s", finding.Severity, finding.Rule, finding.Message)))
+			}
+			s.WriteString("\n")
+		}
+		if m.viewError != "" {
+			s.WriteString("\n")
+			s.WriteString(errorStyle.Render(m.viewError))
+		}
+		if m.writeMessage != "" {
+			s.WriteString("\n")
+			s.WriteString(successStyle.Render(m.writeMessage))
+		}
+
+	case "secret_detail":
+		s.WriteString(titleStyle.Render(fmt.Sprintf("%s version %s", m.viewing.Name, m.detailVersion.VersionId)))
+		s.WriteString("\n")
+		if m.detailLoading {
+			s.WriteString(m.spinner.View())
+			s.WriteString(uiStyle.Render(" Loading value..."))
+		} else {
+			if m.detailVersion.Stages != "" {
+				s.WriteString(dimStyle.Render(m.detailVersion.Stages))
+				s.WriteString("\n")
+			}
+			s.WriteString(m.detailTable.View())
+			if !m.detailJSON {
+				s.WriteString("\n")
+				s.WriteString(dimStyle.Render("Not a JSON object; shown as a single value."))
+			}
+		}
+		if m.viewError != "" {
+			s.WriteString("\n")
+			s.WriteString(errorStyle.Render(m.viewError))
+		}
+
+	case "diff_versions":
+		s.WriteString(m.renderDiff())
+
+	case "edit_secret":
+		s.WriteString(m.renderEditor())
+
+	case "stage_label":
+		version := m.versions[m.versionTable.Cursor()]
+		if m.stageDetach {
+			s.WriteString(titleStyle.Render(fmt.Sprintf("Detach a stage from version %s of %s", version.VersionId, m.viewing.Name)))
+		} else {
+			s.WriteString(titleStyle.Render(fmt.Sprintf("Attach a stage to version %s of %s", version.VersionId, m.viewing.Name)))
+		}
+		s.WriteString("\n")
+		if version.Stages != "" {
+			s.WriteString(dimStyle.Render("Current stages: " + version.Stages))
+			s.WriteString("\n\n")
+		}
+		s.WriteString("Stage: " + m.stageInput.View())
+		if m.viewError != "" {
+			s.WriteString("\n\n")
+			s.WriteString(errorStyle.Render(m.viewError))
+		}
+
+	case "confirm_rotate":
+		s.WriteString(yellowStyle.Render(fmt.Sprintf("Rotate %s now? (y/n)", m.rotateTarget.Name)))
+		s.WriteString("\n\n")
+		s.WriteString(dimStyle.Render("The rotation function creates a new version and makes it current; applications must pick up the new value."))
+		if m.rotating {
+			s.WriteString("\n\n")
+			s.WriteString(m.spinner.View())
+			s.WriteString(uiStyle.Render(" Starting rotation..."))
+		}
+
+	case "confirm_stage":
+		s.WriteString(yellowStyle.Render(fmt.Sprintf("%s (y/n)", m.stageChange.prompt())))
+		s.WriteString("\n\n")
+		if m.stageChange.stage == "AWSCURRENT" {
+			s.WriteString(dimStyle.Render(fmt.Sprintf("Applications reading %s get the new current version; AWSPREVIOUS moves to the version that was current.", m.viewing.Name)))
+		} else {
+			s.WriteString(dimStyle.Render(fmt.Sprintf("Applications reading %s by this stage are affected.", m.viewing.Name)))
+		}
+		if m.stageSaving {
+			s.WriteString("\n\n")
+			s.WriteString(m.spinner.View())
+			s.WriteString(uiStyle.Render(" Updating stages..."))
+		}
+
+	case "excluded":
+		s.WriteString(titleStyle.Render("Excluded by config"))
+		s.WriteString("\n")
+		if len(m.excluded) == 0 {
+			s.WriteString(dimStyle.Render("No secrets were excluded."))
+		} else {
+			s.WriteString(m.excludedTable.View())
+		}
+
+	case "pending":
+		s.WriteString(titleStyle.Render("Scheduled for deletion"))
+		s.WriteString("\n")
+		if m.pendingLoading {
+			s.WriteString(uiStyle.Render("Loading secrets scheduled for deletion..."))
+		} else if len(m.pending) == 0 {
+			s.WriteString(dimStyle.Render("No secrets are scheduled for deletion."))
+		} else {
+			s.WriteString(m.pendingTable.View())
+		}
+		if m.pendingMessage != "" {
+			s.WriteString("\n")
+			s.WriteString(yellowStyle.Render(m.pendingMessage))
+		}
 
 	case "confirm_delete":
-		s.WriteString(errorStyle.Render("Confirm delete selected secrets? (y/n)"))
+		if m.opts.dryRun {
+			s.WriteString(yellowStyle.Render("Dry run: write a deletion plan for the selected secrets? (y/n)"))
+			s.WriteString("\n")
+		} else {
+			s.WriteString(errorStyle.Render("Confirm delete selected secrets? (y/n)"))
+		}
+		s.WriteString("\n\n")
+		if n := m.permanentDeletes(); n > 0 {
+			s.WriteString(errorStyle.Render(fmt.Sprintf("%d of them can't be restored and will be deleted permanently.", n)))
+			s.WriteString("\n")
+		}
+		if window := m.analyzer.config.RecoveryWindowDays; window > 0 {
+			s.WriteString(dimStyle.Render(fmt.Sprintf("Secrets can be restored for %d days where the store supports it.", window)))
+		} else {
+			s.WriteString(dimStyle.Render("Secrets can be restored during the store's default recovery window where supported."))
+		}
+
+	case "deleting":
+		s.WriteString(titleStyle.Render(fmt.Sprintf("Deleting %d secrets", m.deleteTotal)))
+		s.WriteString("\n\n")
+		s.WriteString(m.progress.View())
+		s.WriteString("\n\n")
+		s.WriteString(dimStyle.Render(fmt.Sprintf("%d of %d done", len(m.deleteOutcomes), m.deleteTotal)))
+
+	case "delete_report":
+		failed := 0
+		for _, outcome := range m.deleteOutcomes {
+			if outcome.err != nil {
+				failed++
+			}
+		}
+		s.WriteString(titleStyle.Render("Delete results"))
+		s.WriteString("\n")
+		s.WriteString(m.deleteTable.View())
+		s.WriteString("\n")
+		if failed > 0 {
+			s.WriteString(errorStyle.Render(fmt.Sprintf("%d deleted, %d failed; failed secrets are still selected", len(m.deleteOutcomes)-failed, failed)))
+		} else {
+			s.WriteString(successStyle.Render(fmt.Sprintf("%d deleted", len(m.deleteOutcomes))))
+		}
+
+	case "confirm_force_delete":
+		if m.forceDelete {
+			s.WriteString(errorStyle.Render("FORCE delete selected secrets without any recovery window?"))
+		} else {
+			s.WriteString(errorStyle.Render(fmt.Sprintf("PERMANENTLY delete %d of the selected secrets? Their store can't restore them.", m.permanentDeletes())))
+		}
+		s.WriteString("\n\n")
+		s.WriteString(yellowStyle.Render("This cannot be undone. Confirm again to proceed. (y/n)"))
 
 	case "error":
 		s.WriteString(errorStyle.Render("Failed to initialize AWS connection"))
@@ -778,25 +2964,63 @@ func (m model) View() string {
 		s.WriteString(errorStyle.Render(m.deleteError))
 	}
 
+	if m.planMessage != "" && m.state == "results" {
+		s.WriteString("\n")
+		s.WriteString(yellowStyle.Render(m.planMessage))
+	}
+
+	if m.writeMessage != "" && m.state == "results" {
+		s.WriteString("\n")
+		s.WriteString(yellowStyle.Render(m.writeMessage))
+	}
+
 	if m.copiedMessage != "" {
 		s.WriteString("\n")
 		s.WriteString(successStyle.Render(m.copiedMessage))
+		if m.clipboardValue != "" {
+			remaining := int(time.Until(m.clipboardClearAt).Round(time.Second).Seconds())
+			s.WriteString(dimStyle.Render(fmt.Sprintf(" • clearing in %ds", max(remaining, 0))))
+		}
 	}
 
 	s.WriteString("\n\n")
 	switch m.state {
 	case "results":
-		tooltip := "Enter: View secret • Space: Select • y: Copy name • /: Filter in • ?: Filter out • Shift+D: Delete selected • r: Rescan • R: Rescan all • q: Quit"
+		tooltip := "Enter: View secret • Space: Select • y: Copy name • n: New secret • /: Filter in • ?: Filter out • Shift+D: Delete selected • p: Pending deletion • s: Sort by severity • S: Minimum severity • t: Tags • o: Rotation • O: Rotate now • x: Excluded • r: Rescan • R: Rescan all • q: Quit"
 		if m.hasFilter {
 			tooltip += " • esc: Clear filter"
 		}
 		s.WriteString(dimStyle.Render(tooltip))
 	case "view_secret":
-		s.WriteString(dimStyle.Render("r: Reveal value • y: Copy name • esc: Back • q: Quit"))
-	case "confirm_delete":
+		s.WriteString(dimStyle.Render("Enter: Open value • r: Reveal value • c: Copy value • e: New version • P: Make current • l/u: Attach/detach stage • m: Mark for diff • d: Diff marked • y: Copy name • esc: Back • q: Quit"))
+	case "secret_detail":
+		s.WriteString(dimStyle.Render("r: Reveal/hide key • c: Copy value • esc: Back • q: Quit"))
+	case "diff_versions":
+		if m.diffRevealed {
+			s.WriteString(dimStyle.Render("r: Hide values • esc: Back • q: Quit"))
+		} else {
+			s.WriteString(dimStyle.Render("r: Reveal values • esc: Back • q: Quit"))
+		}
+	case "stage_label":
+		s.WriteString(dimStyle.Render("enter: Continue • esc: Cancel • Ctrl+C: Quit"))
+	case "confirm_stage", "confirm_rotate":
 		s.WriteString(dimStyle.Render("y: Yes • n: No • q: Quit"))
+	case "edit_secret":
+		s.WriteString(dimStyle.Render("Tab: Next field • Ctrl+S: Save • esc: Cancel • Ctrl+C: Quit"))
+	case "excluded":
+		s.WriteString(dimStyle.Render("esc: Back • q: Quit"))
+	case "deleting":
+		s.WriteString(dimStyle.Render("q: Quit"))
+	case "delete_report":
+		s.WriteString(dimStyle.Render("enter/esc: Back • q: Quit"))
+	case "confirm_delete":
+		s.WriteString(dimStyle.Render("y: Yes • f: Force delete • n: No • q: Quit"))
+	case "confirm_force_delete":
+		s.WriteString(dimStyle.Render("y: Yes, delete permanently • n: No • q: Quit"))
+	case "pending":
+		s.WriteString(dimStyle.Render("u: Restore • esc: Back • q: Quit"))
 	case "filter_include", "filter_exclude":
-		s.WriteString(dimStyle.Render("enter: Apply • esc: Cancel • q: Quit"))
+		s.WriteString(dimStyle.Render("enter: Apply • esc: Cancel • Ctrl+C: Quit"))
 	default:
 		s.WriteString(dimStyle.Render("q: Quit"))
 	}
@@ -804,6 +3028,18 @@ func (m model) View() string {
 	return s.String()
 }
 
+// severityStyle colors a finding by how urgent it is
+func severityStyle(severity Severity) lipgloss.Style {
+	switch {
+	case severity >= SeverityHigh:
+		return errorStyle
+	case severity == SeverityMedium:
+		return yellowStyle
+	default:
+		return dimStyle
+	}
+}
+
 func (m model) renderBanner() string {
 	banner := `
     ╔═══════════════════════════════════════════════════════════════╗
@@ -829,16 +3065,145 @@ func (m model) renderScanningProgress() string {
 	return s.String()
 }
 
+func (m model) renderDiff() string {
+	var s strings.Builder
+
+	if m.diffLoading {
+		s.WriteString(titleStyle.Render(fmt.Sprintf("Diff for %s", m.viewing.Name)))
+		s.WriteString("\n\n")
+		s.WriteString(m.spinner.View())
+		s.WriteString(uiStyle.Render(" Loading versions..."))
+		return s.String()
+	}
+
+	s.WriteString(titleStyle.Render(fmt.Sprintf("Diff for %s", m.viewing.Name)))
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render(fmt.Sprintf("from %s (%s) to %s (%s)", m.diffFrom.VersionId, m.diffFrom.Stages, m.diffTo.VersionId, m.diffTo.Stages)))
+	s.WriteString("\n\n")
+
+	mask := func(value string) string {
+		if m.diffRevealed {
+			return value
+		}
+		return "********"
+	}
+
+	// Plain-text lines are labeled with their numbers in both versions
+	lineNumber := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	width := len(strconv.Itoa(len(m.diff)))
+
+	var added, removed, changed int
+	for _, line := range m.diff {
+		label := line.Key
+		if !m.diffJSON {
+			label = fmt.Sprintf("%*s %*s", width, lineNumber(line.OldLine), width, lineNumber(line.NewLine))
+		}
+		switch line.Op {
+		case diffAdded:
+			added++
+			s.WriteString(successStyle.Render(fmt.Sprintf("+ %s: %s", label, mask(line.New))))
+		case diffRemoved:
+			removed++
+			s.WriteString(errorStyle.Render(fmt.Sprintf("- %s: %s", label, mask(line.Old))))
+		case diffChanged:
+			changed++
+			s.WriteString(yellowStyle.Render(fmt.Sprintf("~ %s: %s → %s", label, mask(line.Old), mask(line.New))))
+		default:
+			s.WriteString(dimStyle.Render(fmt.Sprintf("  %s: %s", label, mask(line.New))))
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString("\n")
+	if added+removed+changed == 0 {
+		s.WriteString(successStyle.Render("The versions are identical."))
+	} else if m.diffJSON {
+		s.WriteString(uiStyle.Render(fmt.Sprintf("%d keys added, %d removed, %d changed", added, removed, changed)))
+	} else {
+		s.WriteString(uiStyle.Render(fmt.Sprintf("%d lines added, %d removed", added, removed)))
+	}
+
+	return s.String()
+}
+
+func (m model) renderEditor() string {
+	var s strings.Builder
+
+	if m.editCreate {
+		title := "New secret"
+		if m.editTarget.Account != "" {
+			title += fmt.Sprintf(" in %s %s", m.editTarget.Account, m.editTarget.Region)
+		}
+		s.WriteString(titleStyle.Render(title))
+		s.WriteString("\n")
+		s.WriteString("Name: " + m.editName.View())
+		s.WriteString("\n\n")
+	} else {
+		s.WriteString(titleStyle.Render(fmt.Sprintf("New version of %s", m.editTarget.Name)))
+		s.WriteString("\n")
+	}
+
+	s.WriteString(m.editor.View())
+	s.WriteString("\n")
+	if strings.TrimSpace(m.editor.Value()) == "" {
+		s.WriteString(dimStyle.Render("Enter a value"))
+	} else if isJSON, err := validateSecretValue(m.editor.Value()); err != nil {
+		s.WriteString(errorStyle.Render(err.Error()))
+	} else if isJSON {
+		s.WriteString(successStyle.Render("Valid JSON"))
+	} else {
+		s.WriteString(dimStyle.Render("Plain text"))
+	}
+
+	if !m.editCreate {
+		s.WriteString("\n\n")
+		s.WriteString("Stages: " + m.editStages.View())
+		s.WriteString("\n")
+		s.WriteString(dimStyle.Render("Comma-separated; stages move from the versions that have them"))
+	}
+
+	if m.editSaving {
+		s.WriteString("\n\n")
+		s.WriteString(m.spinner.View())
+		s.WriteString(uiStyle.Render(" Saving..."))
+	}
+	if m.editError != "" {
+		s.WriteString("\n\n")
+		s.WriteString(errorStyle.Render(m.editError))
+	}
+
+	return s.String()
+}
+
 func (m model) renderResults() string {
 	var s strings.Builder
 
 	secretCount := len(m.results)
 
+	var unused, overdue int
+	for _, result := range m.results {
+		if result.Unused {
+			unused++
+		}
+		if result.RotationOverdue {
+			overdue++
+		}
+	}
+
 	if m.filtered {
 		if secretCount > 0 {
-			s.WriteString(yellowStyle.Render(fmt.Sprintf("Found %d potentially unused secrets", secretCount)))
+			headline := fmt.Sprintf("Found %d secrets with findings: %d potentially unused", secretCount, unused)
+			if overdue > 0 {
+				headline += fmt.Sprintf(", %d overdue for rotation", overdue)
+			}
+			s.WriteString(yellowStyle.Render(headline))
 		} else {
-			s.WriteString(successStyle.Render("No potentially unused secrets found"))
+			s.WriteString(successStyle.Render("No secrets with findings found"))
 		}
 	} else {
 		if secretCount > 0 {
@@ -848,6 +3213,23 @@ func (m model) renderResults() string {
 		}
 	}
 
+	if len(m.excluded) > 0 {
+		s.WriteString("\n")
+		s.WriteString(dimStyle.Render(fmt.Sprintf("%d secrets excluded by config (x: view)", len(m.excluded))))
+	}
+
+	var view []string
+	if m.severityFilter {
+		view = append(view, fmt.Sprintf("showing severity %s and above", m.minSeverity))
+	}
+	if m.sortBySeverity {
+		view = append(view, "sorted by severity")
+	}
+	if len(view) > 0 {
+		s.WriteString("\n")
+		s.WriteString(dimStyle.Render(strings.Join(view, " • ")))
+	}
+
 	s.WriteString("\n\n")
 	s.WriteString(titleStyle.Render("Secret Analysis"))
 	s.WriteString("\n")
@@ -867,7 +3249,14 @@ func (m model) renderResults() string {
 	}
 
 	s.WriteString("\n\n")
-	s.WriteString(uiStyle.Render("Scan complete."))
+	if m.scanning {
+		s.WriteString(m.spinner.View())
+		s.WriteString(uiStyle.Render(" Still scanning: " + m.currentScanStep))
+	} else if m.err != nil {
+		s.WriteString(errorStyle.Render(fmt.Sprintf("Scan incomplete: %v", m.err)))
+	} else {
+		s.WriteString(uiStyle.Render(fmt.Sprintf("Scan complete: %d secrets analyzed.", m.scanProgress.Analyzed)))
+	}
 
 	return s.String()
 }
@@ -879,19 +3268,109 @@ func (m model) formatResults() []table.Row {
 		if i < len(m.selected) && m.selected[i] {
 			check = "✔"
 		}
-		rows = append(rows, table.Row{
+		row := table.Row{
 			check,
 			result.Name,
+			result.Account,
+			result.Region,
 			result.LastAccessed,
+			formatSeverity(result),
+		}
+		if m.showRefs {
+			row = append(row, strconv.Itoa(len(result.References)))
+		}
+		if m.showWorkloads {
+			row = append(row, strconv.Itoa(len(result.Workloads)))
+		}
+		if m.showRotation {
+			rules := "off"
+			if result.RotationEnabled {
+				rules = result.RotationRules
+			}
+			if result.RotationOverdue {
+				rules += ", OVERDUE"
+			}
+			row = append(row, rules, result.LastRotated, result.NextRotation)
+		}
+		if m.showTags {
+			row = append(row, formatTags(result.Tags))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (m model) formatExcluded() []table.Row {
+	var rows []table.Row
+	for _, e := range m.excluded {
+		rows = append(rows, table.Row{
+			e.Name,
+			e.Account,
+			e.Region,
+			e.Reason,
+		})
+	}
+	return rows
+}
+
+func (m model) formatPending() []table.Row {
+	var rows []table.Row
+	for _, p := range m.pending {
+		deletedStr := ""
+		if p.entry.DeletedDate != nil {
+			deletedStr = p.entry.DeletedDate.Format("2006-01-02 15:04")
+		}
+		rows = append(rows, table.Row{
+			p.entry.Name,
+			p.entry.Account,
+			p.entry.Region,
+			deletedStr,
+		})
+	}
+	return rows
+}
+
+func (m model) formatDeleteOutcomes() []table.Row {
+	var rows []table.Row
+	for _, outcome := range m.deleteOutcomes {
+		status := "Deleted"
+		if outcome.err != nil {
+			status = "Failed: " + strings.ReplaceAll(outcome.err.Error(), "\n", "; ")
+		}
+		if outcome.attempts > 1 {
+			status += fmt.Sprintf(" (%d attempts)", outcome.attempts)
+		}
+		rows = append(rows, table.Row{
+			outcome.result.Name,
+			outcome.result.Account,
+			outcome.result.Region,
+			status,
 		})
 	}
 	return rows
 }
 
+func (m model) formatDetail() []table.Row {
+	var rows []table.Row
+	for _, field := range m.detailFields {
+		value := "********"
+		if field.Revealed {
+			value = field.Value
+		}
+		rows = append(rows, table.Row{field.Key, value})
+	}
+	return rows
+}
+
 func (m model) formatVersions() []table.Row {
 	var rows []table.Row
 	for _, v := range m.versions {
+		mark := " "
+		if v.Marked {
+			mark = "✔"
+		}
 		rows = append(rows, table.Row{
+			mark,
 			v.VersionId,
 			v.CreatedDate,
 			v.LastAccessed,
@@ -903,7 +3382,24 @@ func (m model) formatVersions() []table.Row {
 }
 
 func main() {
-	m := initialModel()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "scan":
+			os.Exit(runScan(os.Args[2:]))
+		case "apply":
+			os.Exit(runApply(os.Args[2:]))
+		case "audit":
+			os.Exit(runAudit(os.Args[2:]))
+		case "refs":
+			os.Exit(runRefs(os.Args[2:]))
+		}
+	}
+
+	var opts options
+	opts.register(flag.CommandLine)
+	flag.Parse()
+
+	m := initialModel(opts)
 
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
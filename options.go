@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Secret store backends selectable with --source
+const (
+	sourceSecretsManager = "secretsmanager"
+	sourceSSM            = "ssm"
+	sourceVault          = "vault"
+)
+
+// options are the flags shared by the TUI and the headless commands
+type options struct {
+	configPath   string
+	threshold    *int
+	recovery     *int
+	clipboard    *int
+	source       string
+	regions      string
+	profiles     string
+	roleARNs     string
+	vaultMount   string
+	vaultDestroy bool
+	dryRun       bool
+	planFile     string
+	auditLog     string
+	deep         bool
+	cloudTrail   bool
+	refs         string
+	k8sManifests string
+	k8sContexts  string
+	minSeverity  *Severity
+}
+
+// register adds every flag of the TUI
+func (o *options) register(fs *flag.FlagSet) {
+	o.registerConfig(fs)
+	o.registerAccounts(fs)
+	o.registerVault(fs)
+	o.registerAnalysis(fs)
+	fs.Func("recovery-window", "Number of `days` deleted secrets can be restored, 7 to 30 (default: the store's default, or the config file's recovery_window_days)", func(s string) error {
+		days, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("must be a number of days")
+		}
+		if err := validateRecoveryWindow(days); err != nil {
+			return err
+		}
+		o.recovery = &days
+		return nil
+	})
+	fs.Func("clipboard-clear", fmt.Sprintf("Number of `seconds` before a copied secret value is cleared from the clipboard, 0 to keep it (default %d, or the config file's clipboard_clear_seconds)", defaultClipboardClearSeconds), func(s string) error {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("must be a non-negative number of seconds")
+		}
+		o.clipboard = &seconds
+		return nil
+	})
+	fs.BoolVar(&o.dryRun, "dry-run", false, "Write a deletion plan for review instead of deleting secrets")
+	fs.StringVar(&o.planFile, "plan-file", "", "Path of the deletion plan written in dry-run mode (default sniffy-plan-<timestamp>.json)")
+}
+
+// registerAnalysis adds the flags that choose what is scanned and how
+// secrets are analyzed
+func (o *options) registerAnalysis(fs *flag.FlagSet) {
+	fs.Func("threshold", fmt.Sprintf("Number of `days` without access after which a secret is potentially unused (default %d, or the config file's threshold)", defaultThresholdDays), func(s string) error {
+		days, err := strconv.Atoi(s)
+		if err != nil || days < 0 {
+			return fmt.Errorf("must be a non-negative number of days")
+		}
+		o.threshold = &days
+		return nil
+	})
+	fs.Func("min-severity", "Lowest `severity` of finding that reports a secret: info, low, medium, high or critical (default medium, or the config file's findings.min_severity)", func(s string) error {
+		severity, err := parseSeverity(s)
+		if err != nil {
+			return err
+		}
+		o.minSeverity = &severity
+		return nil
+	})
+	fs.BoolVar(&o.deep, "deep", false, "Also run the checks that need an API call per secret: deprecated versions and resource policies")
+	fs.BoolVar(&o.cloudTrail, "cloudtrail", false, "Look up who last read each secret, from where and when, in CloudTrail (Secrets Manager only)")
+	fs.StringVar(&o.refs, "refs", "", "Comma-separated `dirs` to search for files referencing each secret (default: the config file's references)")
+	fs.StringVar(&o.k8sManifests, "k8s-manifests", "", "Comma-separated `dirs` of ExternalSecret, SecretStore and SecretProviderClass manifests (default: the config file's kubernetes.manifests)")
+	fs.StringVar(&o.k8sContexts, "k8s-contexts", "", "Comma-separated kubeconfig `contexts` to read ExternalSecrets, SecretStores and SecretProviderClasses from with kubectl (default: the config file's kubernetes.contexts)")
+	fs.StringVar(&o.source, "source", sourceSecretsManager, "Secret store to analyze: secretsmanager, ssm or vault")
+	fs.StringVar(&o.regions, "regions", "", "Comma-separated AWS regions to scan, or \"all\" for every enabled region (default: the configured region)")
+}
+
+// registerConfig adds the flags that say which config file and audit log
+// to use
+func (o *options) registerConfig(fs *flag.FlagSet) {
+	fs.StringVar(&o.configPath, "config", "", "Path to the config file (default ~/.config/sniffy/config.yaml)")
+	fs.StringVar(&o.auditLog, "audit-log", "", "Path of the audit log of reveals, copies, writes and deletes (default ~/.local/state/sniffy/audit.jsonl, or the config file's audit_log)")
+}
+
+// registerAccounts adds the flags that say which AWS accounts to reach
+func (o *options) registerAccounts(fs *flag.FlagSet) {
+	fs.StringVar(&o.profiles, "profiles", "", "Comma-separated AWS profiles to scan, one per account")
+	fs.StringVar(&o.roleARNs, "role-arns", "", "Comma-separated IAM role ARNs to assume, one per account")
+}
+
+// registerVault adds the flags that configure the Vault store
+func (o *options) registerVault(fs *flag.FlagSet) {
+	fs.StringVar(&o.vaultMount, "vault-mount", "secret", "Path of the Vault KV v2 mount to analyze")
+	fs.BoolVar(&o.vaultDestroy, "vault-destroy", false, "Permanently destroy Vault secrets instead of soft-deleting them")
+}
+
+// loadConfig reads the config file and applies flag overrides to it
+func (o options) loadConfig() (*Config, error) {
+	cfg, err := LoadConfig(o.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if o.threshold != nil {
+		cfg.Threshold = *o.threshold
+	}
+	if o.recovery != nil {
+		cfg.RecoveryWindowDays = *o.recovery
+	}
+	if o.clipboard != nil {
+		cfg.ClipboardClearSeconds = *o.clipboard
+	}
+	if o.auditLog != "" {
+		cfg.AuditLog = o.auditLog
+	}
+	if o.minSeverity != nil {
+		cfg.Findings.MinSeverity = o.minSeverity.String()
+		cfg.Findings.minSeverity = *o.minSeverity
+	}
+	if o.deep {
+		cfg.Findings.Deep = true
+	}
+	if o.cloudTrail {
+		cfg.CloudTrail.Enabled = true
+	}
+	if o.refs != "" {
+		cfg.References = splitList(o.refs)
+	}
+	if o.k8sManifests != "" {
+		cfg.Kubernetes.Manifests = splitList(o.k8sManifests)
+	}
+	if o.k8sContexts != "" {
+		cfg.Kubernetes.Contexts = splitList(o.k8sContexts)
+	}
+	return cfg, nil
+}
+
+// newSecretStores builds one store per location to scan. AWS sources get a
+// store per account and region; Vault is a single store.
+func newSecretStores(ctx context.Context, o options) ([]SecretStore, error) {
+	switch o.source {
+	case sourceSecretsManager, sourceSSM:
+	case sourceVault:
+		store, err := NewVaultKVStore(o.vaultMount, o.vaultDestroy)
+		if err != nil {
+			return nil, err
+		}
+		return []SecretStore{store}, nil
+	default:
+		return nil, fmt.Errorf("unknown source %q (want %s, %s or %s)", o.source, sourceSecretsManager, sourceSSM, sourceVault)
+	}
+
+	accounts, err := loadAWSAccounts(ctx, splitList(o.profiles), splitList(o.roleARNs))
+	if err != nil {
+		return nil, err
+	}
+
+	var stores []SecretStore
+	for _, account := range accounts {
+		var regions []string
+		switch o.regions {
+		case "":
+			regions = []string{account.cfg.Region}
+		case "all":
+			// Enabled regions can differ between accounts
+			regions, err = discoverRegions(ctx, account.cfg)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %w", account.id, err)
+			}
+		default:
+			regions = splitList(o.regions)
+		}
+
+		for _, region := range regions {
+			regionCfg := account.cfg.Copy()
+			regionCfg.Region = region
+			if o.source == sourceSSM {
+				stores = append(stores, NewAWSParameterStore(regionCfg, account.id, account.arn))
+			} else {
+				stores = append(stores, NewAWSSecretsManager(regionCfg, account.id, account.arn))
+			}
+		}
+	}
+
+	return stores, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
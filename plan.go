@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DeletionPlan is written instead of deleting anything in dry-run mode, so a
+// second person can review it before `sniffy apply` executes it.
+type DeletionPlan struct {
+	CreatedAt          time.Time   `json:"created_at"`
+	Source             string      `json:"source"`
+	RecoveryWindowDays int         `json:"recovery_window_days,omitempty"`
+	Force              bool        `json:"force"`
+	Secrets            []PlanEntry `json:"secrets"`
+
+	// VaultMount and VaultDestroy are the Vault settings the plan was made
+	// with; apply refuses to run with different ones
+	VaultMount   string `json:"vault_mount,omitempty"`
+	VaultDestroy bool   `json:"vault_destroy,omitempty"`
+}
+
+type PlanEntry struct {
+	Name         string            `json:"name"`
+	ARN          string            `json:"arn,omitempty"`
+	Account      string            `json:"account,omitempty"`
+	Region       string            `json:"region,omitempty"`
+	LastAccessed string            `json:"last_accessed"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+// locatedStore is implemented by stores bound to one AWS account and region
+type locatedStore interface {
+	Location() (account, region string)
+}
+
+func storeLocation(store SecretStore) (string, string) {
+	if located, ok := store.(locatedStore); ok {
+		return located.Location()
+	}
+	return "", ""
+}
+
+// planStore finds the store for a plan entry's account and region, or nil
+// when there are no credentials for it
+func planStore(stores []SecretStore, entry PlanEntry) SecretStore {
+	for _, store := range stores {
+		account, region := storeLocation(store)
+		if account == entry.Account && region == entry.Region {
+			return store
+		}
+	}
+	return nil
+}
+
+func defaultPlanPath() string {
+	return fmt.Sprintf("sniffy-plan-%s.json", time.Now().Format("20060102-150405"))
+}
+
+func writePlan(path string, plan DeletionPlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Refuse to overwrite a plan that may already be under review
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+func readPlan(path string) (*DeletionPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	var plan DeletionPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	return &plan, nil
+}
+
+// runApply executes a reviewed deletion plan. The plan decides the source,
+// regions and delete options; credentials for the accounts come from the
+// usual flags.
+func runApply(args []string) int {
+	var opts options
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	opts.registerConfig(fs)
+	opts.registerAccounts(fs)
+	opts.registerVault(fs)
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sniffy apply [flags] plan.json")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	plan, err := readPlan(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	if len(plan.Secrets) == 0 {
+		fmt.Println("Plan contains no secrets.")
+		return exitOK
+	}
+
+	// Build stores for exactly the regions named in the plan
+	opts.source = plan.Source
+	if plan.Source == sourceVault && plan.VaultMount != "" {
+		set := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if set["vault-mount"] && opts.vaultMount != plan.VaultMount {
+			fmt.Fprintf(os.Stderr, "Error: plan was made for Vault mount %q, not %q\n", plan.VaultMount, opts.vaultMount)
+			return exitError
+		}
+		if set["vault-destroy"] && opts.vaultDestroy != plan.VaultDestroy {
+			fmt.Fprintf(os.Stderr, "Error: plan was made with --vault-destroy=%t, not %t\n", plan.VaultDestroy, opts.vaultDestroy)
+			return exitError
+		}
+		opts.vaultMount, opts.vaultDestroy = plan.VaultMount, plan.VaultDestroy
+	}
+	var regions []string
+	seen := map[string]bool{}
+	for _, entry := range plan.Secrets {
+		if entry.Region != "" && !seen[entry.Region] {
+			seen[entry.Region] = true
+			regions = append(regions, entry.Region)
+		}
+	}
+	opts.regions = strings.Join(regions, ",")
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	audit := NewAuditLog(cfg.AuditLog)
+
+	ctx := context.Background()
+	stores, err := newSecretStores(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	deleteOpts := DeleteOptions{
+		RecoveryWindowDays: plan.RecoveryWindowDays,
+		Force:              plan.Force,
+	}
+
+	fmt.Printf("Plan created %s deletes %d secrets from %s", plan.CreatedAt.Format("2006-01-02 15:04"), len(plan.Secrets), plan.Source)
+	if plan.Source == sourceVault && opts.vaultMount != "" {
+		fmt.Printf(" mount %s", opts.vaultMount)
+	}
+	if plan.Force || slices.ContainsFunc(stores, deletesPermanently) {
+		fmt.Print(" WITHOUT any recovery window")
+	} else if plan.RecoveryWindowDays > 0 {
+		fmt.Printf(" with a %d-day recovery window", plan.RecoveryWindowDays)
+	}
+	fmt.Println(":")
+	for _, entry := range plan.Secrets {
+		fmt.Printf("  %s\t%s\t%s\tlast accessed %s\n", entry.Name, entry.Account, entry.Region, entry.LastAccessed)
+	}
+
+	if !*yes {
+		fmt.Print("Proceed? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			fmt.Println("Aborted.")
+			return exitOK
+		}
+	}
+
+	failed := 0
+	var secrets []SecretResult
+	for _, entry := range plan.Secrets {
+		store := planStore(stores, entry)
+		if store == nil {
+			fmt.Fprintf(os.Stderr, "FAILED %s: no credentials for account %q in region %q\n", entry.Name, entry.Account, entry.Region)
+			failed++
+			continue
+		}
+		secrets = append(secrets, SecretResult{
+			Name:    entry.Name,
+			ARN:     entry.ARN,
+			Account: entry.Account,
+			Region:  entry.Region,
+			store:   store,
+		})
+	}
+
+	for outcome := range deleteSecrets(ctx, secrets, deleteOpts, audit) {
+		if outcome.err != nil {
+			fmt.Fprintf(os.Stderr, "FAILED %s: %v\n", outcome.result.Name, outcome.err)
+			failed++
+			continue
+		}
+		fmt.Printf("Deleted %s\n", outcome.result.Name)
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d deletions failed\n", failed, len(plan.Secrets))
+		return exitError
+	}
+
+	return exitOK
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestPlanStore(t *testing.T) {
+	prodEast := &AWSSecretsManager{account: "111111111111", region: "us-east-1"}
+	prodWest := &AWSSecretsManager{account: "111111111111", region: "us-west-2"}
+	stagingEast := &AWSParameterStore{account: "222222222222", region: "us-east-1"}
+	stores := []SecretStore{prodEast, prodWest, stagingEast}
+
+	tests := []struct {
+		name  string
+		entry PlanEntry
+		want  SecretStore
+	}{
+		{"account and region", PlanEntry{Name: "db", Account: "111111111111", Region: "us-west-2"}, prodWest},
+		{"other account", PlanEntry{Name: "db", Account: "222222222222", Region: "us-east-1"}, stagingEast},
+		{"no credentials for account", PlanEntry{Name: "db", Account: "333333333333", Region: "us-east-1"}, nil},
+		{"region not loaded", PlanEntry{Name: "db", Account: "222222222222", Region: "us-west-2"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planStore(stores, tt.entry); got != tt.want {
+				t.Errorf("planStore(%s/%s) = %v, want %v", tt.entry.Account, tt.entry.Region, got, tt.want)
+			}
+		})
+	}
+
+	// Vault stores have no location and match entries without one
+	vault := &VaultKVStore{mount: "secret"}
+	if got := planStore([]SecretStore{prodEast, vault}, PlanEntry{Name: "app/db"}); got != vault {
+		t.Errorf("planStore(vault entry) = %v, want the Vault store", got)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxReferenceLine is how much of a line is searched; minified JSON and
+// Terraform state can have very long lines, and the rest of one is skipped.
+// It's a variable so tests can shorten it.
+var maxReferenceLine = 16 * 1024 * 1024
+
+// referenceExtensions are the files searched for secret references
+var referenceExtensions = map[string]bool{
+	// Infrastructure as code and config
+	".tf": true, ".tfvars": true, ".tfstate": true, ".hcl": true,
+	".yaml": true, ".yml": true, ".json": true, ".env": true,
+	".toml": true, ".ini": true, ".conf": true, ".properties": true, ".tpl": true,
+	// Source
+	".go": true, ".py": true, ".js": true, ".mjs": true, ".ts": true, ".jsx": true, ".tsx": true,
+	".java": true, ".kt": true, ".scala": true, ".rb": true, ".php": true, ".cs": true,
+	".rs": true, ".swift": true, ".sh": true, ".bash": true, ".ps1": true,
+}
+
+// referenceSkipDirs are never descended into
+var referenceSkipDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, ".terraform": true,
+}
+
+var (
+	// secretNameSeparator splits a line into words that could be secret or
+	// parameter names; names can't contain ':', so it also splits
+	// references like "{{resolve:secretsmanager:prod/db:SecretString}}"
+	secretNameSeparator = regexp.MustCompile(`[^A-Za-z0-9/_+=.@-]+`)
+	secretARNPattern    = regexp.MustCompile(`arn:aws[a-z-]*:secretsmanager:[a-z0-9-]+:[0-9]{12}:secret:[A-Za-z0-9/_+=.@-]+`)
+)
+
+// Reference is a line of a local file that mentions a secret
+type Reference struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%s:%d", r.File, r.Line)
+}
+
+// referenceIndex maps the secret names and ARNs found in local files to the
+// lines they were found on
+type referenceIndex map[string][]Reference
+
+// isReferenceFile reports whether a file is searched for references
+func isReferenceFile(name string) bool {
+	base := strings.ToLower(name)
+	// .env, .env.production, prod.env
+	if base == ".env" || strings.HasPrefix(base, ".env.") || base == "dockerfile" {
+		return true
+	}
+	return referenceExtensions[filepath.Ext(base)]
+}
+
+// indexReferences walks the directories and indexes where every file that
+// could reference a secret mentions one of the keys
+func indexReferences(dirs []string, keys map[string]bool) (referenceIndex, error) {
+	index := referenceIndex{}
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != dir && referenceSkipDirs[d.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || !isReferenceFile(d.Name()) {
+				return nil
+			}
+			return index.addFile(path, keys)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s for references: %w", dir, err)
+		}
+	}
+	return index, nil
+}
+
+func (idx referenceIndex) addFile(path string, keys map[string]bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		text, err := readLine(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ref := Reference{File: path, Line: line}
+
+		// A line counts once per key, however often the key appears on it
+		seen := map[string]bool{}
+		add := func(word string) {
+			if keys[word] && !seen[word] {
+				seen[word] = true
+				idx[word] = append(idx[word], ref)
+			}
+		}
+		for _, arn := range secretARNPattern.FindAllString(text, -1) {
+			add(arn)
+		}
+		for _, word := range secretNameSeparator.Split(text, -1) {
+			// Names can contain '=', but in .env files and shell scripts it
+			// usually assigns one: DB_SECRET=prod/db
+			for _, part := range append(strings.Split(word, "="), word) {
+				add(part)
+				// Names at the end of a sentence
+				add(strings.TrimRight(part, "."))
+			}
+		}
+	}
+}
+
+// readLine reads a line without its line ending, keeping at most
+// maxReferenceLine bytes of it
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if room := maxReferenceLine - len(line); room > 0 {
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		// The last line needn't end in a newline
+		if err == io.EOF && len(line) > 0 {
+			err = nil
+		}
+		return strings.TrimRight(string(line), "\r\n"), err
+	}
+}
+
+// secretKeys are the IDs a secret can be referred to by: its name, its ARN
+// and its partial ARN, the ARN without the "-" and six random characters
+// Secrets Manager adds
+func secretKeys(entry SecretEntry) []string {
+	keys := []string{entry.Name}
+	if entry.ARN != "" {
+		keys = append(keys, entry.ARN)
+		if n := len(entry.ARN) - 7; n > 0 && entry.ARN[n] == '-' && strings.HasSuffix(entry.ARN[:n], ":secret:"+entry.Name) {
+			keys = append(keys, entry.ARN[:n])
+		}
+	}
+	return keys
+}
+
+// Lookup returns the lines that mention a secret by any of its keys
+func (idx referenceIndex) Lookup(entry SecretEntry) []Reference {
+	var refs []Reference
+	seen := map[Reference]bool{}
+	for _, key := range secretKeys(entry) {
+		for _, ref := range idx[key] {
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// runRefs scans secrets and lists where local repositories reference them
+func runRefs(args []string) int {
+	var opts options
+	fs := flag.NewFlagSet("refs", flag.ContinueOnError)
+	opts.registerConfig(fs)
+	opts.registerAccounts(fs)
+	opts.registerVault(fs)
+	opts.registerAnalysis(fs)
+	format := fs.String("format", "table", "Output format: table or json")
+	all := fs.Bool("all", false, "List all secrets, not just those with findings")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sniffy refs [flags] <dir>...")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (want table or json)\n", *format)
+		return exitError
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	cfg.References = fs.Args()
+
+	ctx := context.Background()
+	stores, err := newSecretStores(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	results, _, err := NewSecretAnalyzer(cfg, stores...).AnalyzeSecrets(ctx, !*all)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	if *format == "json" {
+		err = writeJSON(os.Stdout, results)
+	} else {
+		err = writeRefs(os.Stdout, results)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write results: %v\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// writeRefs prints a table of reference counts, then the references of each
+// referenced secret
+func writeRefs(w io.Writer, results []SecretResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SECRET\tACCOUNT\tREGION\tLAST ACCESSED\tSEVERITY\tREFS")
+	for _, result := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n", result.Name, result.Account, result.Region, result.LastAccessed, formatSeverity(result), len(result.References))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if len(result.References) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\n", result.Name)
+		for _, ref := range result.References {
+			fmt.Fprintf(w, "  %s\n", ref)
+		}
+	}
+	return nil
+}
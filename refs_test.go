@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReferenceLookup(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.tf":              "secret_id = \"prod/db\"\narn = \"" + testSecretARN + "prod/db-a1B2c3\"\n",
+		"app.py":               "client.get_secret_value(SecretId=\"" + testSecretARN + "prod/db\")\n",
+		"deploy/values.yaml":   "# rotated from prod/db.\nname: prod/db prod/db\n",
+		".env":                 "DB_SECRET=prod/db-old\n",
+		"README.md":            "Reads prod/db\n",
+		"node_modules/x/a.js":  "const secret = 'prod/db'\n",
+		"template.yaml.sample": "prod/db\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		entry SecretEntry
+		want  []Reference
+	}{
+		{
+			name:  "name, ARN and partial ARN",
+			entry: SecretEntry{Name: "prod/db", ARN: testSecretARN + "prod/db-a1B2c3"},
+			want: []Reference{
+				{File: filepath.Join(dir, "app.py"), Line: 1},
+				{File: filepath.Join(dir, "deploy/values.yaml"), Line: 1},
+				{File: filepath.Join(dir, "deploy/values.yaml"), Line: 2},
+				{File: filepath.Join(dir, "main.tf"), Line: 1},
+				{File: filepath.Join(dir, "main.tf"), Line: 2},
+			},
+		},
+		{
+			name:  "assigned in a .env file",
+			entry: SecretEntry{Name: "prod/db-old", ARN: testSecretARN + "prod/db-old-d4E5f6"},
+			want:  []Reference{{File: filepath.Join(dir, ".env"), Line: 1}},
+		},
+		{
+			name:  "not referenced",
+			entry: SecretEntry{Name: "prod/cache"},
+		},
+	}
+
+	keys := map[string]bool{}
+	for _, tt := range tests {
+		for _, key := range secretKeys(tt.entry) {
+			keys[key] = true
+		}
+	}
+	index, err := indexReferences([]string{dir}, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the listed secrets' keys are indexed
+	if refs, ok := index["secret_id"]; ok {
+		t.Errorf("index has %v for a word that isn't a key", refs)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := index.Lookup(tt.entry)
+			sort.Slice(got, func(i, j int) bool { return got[i].String() < got[j].String() })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup(%s) = %v, want %v", tt.entry.Name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferenceLongLine(t *testing.T) {
+	old := maxReferenceLine
+	maxReferenceLine = 64
+	t.Cleanup(func() { maxReferenceLine = old })
+
+	// The end of the long line is past the limit and isn't searched
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	content := "prod/db " + strings.Repeat("x", 200) + " prod/cache\nprod/cache\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := indexReferences([]string{filepath.Dir(path)}, map[string]bool{"prod/db": true, "prod/cache": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := index.Lookup(SecretEntry{Name: "prod/db"}), []Reference{{File: path, Line: 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup(prod/db) = %v, want %v", got, want)
+	}
+	if got, want := index.Lookup(SecretEntry{Name: "prod/cache"}), []Reference{{File: path, Line: 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup(prod/cache) = %v, want %v", got, want)
+	}
+}
+
+func TestIsReferenceFile(t *testing.T) {
+	tests := map[string]bool{
+		"main.tf":           true,
+		"terraform.tfstate": true,
+		"values.YAML":       true,
+		".env":              true,
+		".env.production":   true,
+		"Dockerfile":        true,
+		"README.md":         false,
+		"app.py.orig":       false,
+	}
+	for name, want := range tests {
+		if got := isReferenceFile(name); got != want {
+			t.Errorf("isReferenceFile(%q) = %t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestSecretKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry SecretEntry
+		want  []string
+	}{
+		{
+			name:  "name only",
+			entry: SecretEntry{Name: "/prod/api-key"},
+			want:  []string{"/prod/api-key"},
+		},
+		{
+			name:  "with ARN",
+			entry: SecretEntry{Name: "prod/db", ARN: testSecretARN + "prod/db-a1B2c3"},
+			want:  []string{"prod/db", testSecretARN + "prod/db-a1B2c3", testSecretARN + "prod/db"},
+		},
+		{
+			name:  "name with a hyphen",
+			entry: SecretEntry{Name: "prod/db-old", ARN: testSecretARN + "prod/db-old-d4E5f6"},
+			want:  []string{"prod/db-old", testSecretARN + "prod/db-old-d4E5f6", testSecretARN + "prod/db-old"},
+		},
+		{
+			name:  "ARN without a random suffix",
+			entry: SecretEntry{Name: "/prod/api-key", ARN: "arn:aws:ssm:us-east-1:111111111111:parameter/prod/api-key"},
+			want:  []string{"/prod/api-key", "arn:aws:ssm:us-east-1:111111111111:parameter/prod/api-key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := secretKeys(tt.entry); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("secretKeys() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeSecretsReferences(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte("name = \"app/db\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := newTestVaultStore(t, false)
+	results, _, err := NewSecretAnalyzer(&Config{References: []string{dir}}, store).AnalyzeSecrets(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]int{}
+	for _, result := range results {
+		got[result.Name] = len(result.References)
+	}
+	if want := map[string]int{"top": 0, "app/db": 1, "app/cache/redis": 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("references = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// discoverRegions returns the regions enabled for the account by calling
+// EC2 DescribeRegions, which only lists opted-in regions by default
+func discoverRegions(ctx context.Context, cfg aws.Config) ([]string, error) {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	out, err := ec2.NewFromConfig(cfg).DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover regions: %w", err)
+	}
+	if len(out.Regions) == 0 {
+		return nil, fmt.Errorf("failed to discover regions: no enabled regions returned")
+	}
+
+	regions := make([]string, 0, len(out.Regions))
+	for _, region := range out.Regions {
+		regions = append(regions, aws.ToString(region.RegionName))
+	}
+	return regions, nil
+}
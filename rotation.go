@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SecretRotator is implemented by stores that can rotate a secret on demand
+type SecretRotator interface {
+	RotateSecret(ctx context.Context, secretName string) error
+}
+
+// RotationSettings describes how a store rotates a secret. The zero value
+// means rotation is off.
+type RotationSettings struct {
+	Enabled   bool
+	LambdaARN string
+	// AfterDays and Schedule are the rotation rules; a secret has one or
+	// the other
+	AfterDays        int
+	Schedule         string
+	LastRotatedDate  *time.Time
+	NextRotationDate *time.Time
+}
+
+// Rules describes the rotation schedule for display
+func (r RotationSettings) Rules() string {
+	switch {
+	case !r.Enabled:
+		return "disabled"
+	case r.AfterDays > 0:
+		return fmt.Sprintf("every %d days", r.AfterDays)
+	default:
+		return r.Schedule
+	}
+}
+
+// Overdue reports whether a rotation should have happened by now. Without a
+// scheduled date the interval is counted from the last rotation, or from
+// creation for a secret that was never rotated.
+func (r RotationSettings) Overdue(created *time.Time, now time.Time) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.NextRotationDate != nil {
+		return now.After(*r.NextRotationDate)
+	}
+	if r.AfterDays == 0 {
+		return false
+	}
+	last := r.LastRotatedDate
+	if last == nil {
+		last = created
+	}
+	return last != nil && now.Sub(*last) > time.Duration(r.AfterDays)*24*time.Hour
+}
+
+// formatDate renders an optional date, empty when unset
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotationOverdue(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		t := now.AddDate(0, 0, -days)
+		return &t
+	}
+
+	tests := []struct {
+		name     string
+		settings RotationSettings
+		created  *time.Time
+		want     bool
+	}{
+		{"disabled", RotationSettings{AfterDays: 30}, daysAgo(100), false},
+		{"next rotation passed", RotationSettings{Enabled: true, NextRotationDate: daysAgo(1)}, daysAgo(100), true},
+		{"next rotation ahead", RotationSettings{Enabled: true, AfterDays: 30, NextRotationDate: daysAgo(-1), LastRotatedDate: daysAgo(100)}, daysAgo(100), false},
+		{"interval elapsed since last rotation", RotationSettings{Enabled: true, AfterDays: 30, LastRotatedDate: daysAgo(31)}, daysAgo(100), true},
+		{"within interval", RotationSettings{Enabled: true, AfterDays: 30, LastRotatedDate: daysAgo(29)}, daysAgo(100), false},
+		{"never rotated, counted from creation", RotationSettings{Enabled: true, AfterDays: 30}, daysAgo(31), true},
+		{"schedule without a date", RotationSettings{Enabled: true, Schedule: "rate(7 days)"}, daysAgo(100), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.Overdue(tt.created, now); got != tt.want {
+				t.Errorf("Overdue() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes for the headless scan
+const (
+	exitOK     = 0
+	exitUnused = 1 // a potentially unused secret, or any finding at the minimum severity
+	exitError  = 2
+)
+
+// runScan analyzes secrets without the TUI and prints the results to stdout.
+// It exits non-zero when a secret is potentially unused or has a finding at the
+// minimum severity, so it can gate CI pipelines and cron jobs.
+func runScan(args []string) int {
+	var opts options
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	opts.registerConfig(fs)
+	opts.registerAccounts(fs)
+	opts.registerVault(fs)
+	opts.registerAnalysis(fs)
+	format := fs.String("format", "table", "Output format: table, json or csv")
+	all := fs.Bool("all", false, "List all secrets, not just potentially unused ones")
+	showExcluded := fs.Bool("show-excluded", false, "List the secrets excluded by the config on stderr")
+	showTags := fs.Bool("show-tags", false, "Add a tags column to table output")
+	showRotation := fs.Bool("show-rotation", false, "Add rotation columns to table output")
+	filterQuery := fs.String("filter", "", "Only output secrets matching the filter, e.g. \"owner=payments !env=prod\"")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sniffy scan [flags]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+
+	// Table output gets CloudTrail and reference columns when the config
+	// turns them on
+	var showLastRead, showRefs, showWorkloads bool
+	var write func(io.Writer, []SecretResult) error
+	switch *format {
+	case "table":
+		write = func(w io.Writer, results []SecretResult) error {
+			return writeTable(w, results, *showTags, *showRotation, showLastRead, showRefs, showWorkloads)
+		}
+	case "json":
+		write = writeJSON
+	case "csv":
+		write = writeCSV
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (want table, json or csv)\n", *format)
+		return exitError
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	showLastRead = cfg.CloudTrail.Enabled
+	showRefs = len(cfg.References) > 0
+	showWorkloads = cfg.Kubernetes.Enabled()
+
+	ctx := context.Background()
+	stores, err := newSecretStores(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	analyzer := NewSecretAnalyzer(cfg, stores...)
+
+	results, excluded, err := analyzer.AnalyzeSecrets(ctx, !*all)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	// Exclusions never disappear silently; they are summarized on stderr so
+	// they don't pollute machine-readable output
+	if len(excluded) > 0 {
+		fmt.Fprintf(os.Stderr, "%d secrets excluded by config\n", len(excluded))
+		if *showExcluded {
+			for _, e := range excluded {
+				fmt.Fprintf(os.Stderr, "  %s: %s\n", e.Name, e.Reason)
+			}
+		}
+	}
+
+	if *filterQuery != "" {
+		filter := parseFilter(*filterQuery)
+		var filtered []SecretResult
+		for _, result := range results {
+			if filter.Match(result) {
+				filtered = append(filtered, result)
+			}
+		}
+		results = filtered
+	}
+
+	if err := write(os.Stdout, results); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write results: %v\n", err)
+		return exitError
+	}
+
+	for _, result := range results {
+		if result.flagged(cfg.Findings.minSeverity) {
+			return exitUnused
+		}
+	}
+
+	return exitOK
+}
+
+func writeTable(w io.Writer, results []SecretResult, showTags, showRotation, showLastRead, showRefs, showWorkloads bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "SECRET\tACCOUNT\tREGION\tLAST ACCESSED\tUNUSED\tSEVERITY\tFINDINGS"
+	if showRefs {
+		header += "\tREFS"
+	}
+	if showWorkloads {
+		header += "\tWORKLOADS"
+	}
+	if showRotation {
+		header += "\tROTATION\tLAST ROTATED\tNEXT ROTATION\tOVERDUE"
+	}
+	if showLastRead {
+		header += "\tLAST READ\tREAD BY\tREAD FROM"
+	}
+	if showTags {
+		header += "\tTAGS"
+	}
+	fmt.Fprintln(tw, header)
+	for _, result := range results {
+		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%t\t%s\t%s", result.Name, result.Account, result.Region, result.LastAccessed, result.Unused, formatSeverity(result), formatFindingRules(result.Findings))
+		if showRefs {
+			line += fmt.Sprintf("\t%d", len(result.References))
+		}
+		if showWorkloads {
+			line += "\t" + formatWorkloads(result.Workloads)
+		}
+		if showRotation {
+			rules := "off"
+			if result.RotationEnabled {
+				rules = result.RotationRules
+			}
+			line += fmt.Sprintf("\t%s\t%s\t%s\t%t", rules, result.LastRotated, result.NextRotation, result.RotationOverdue)
+		}
+		if showLastRead {
+			readAt, readBy, readFrom := formatLastRead(result.LastRead)
+			line += fmt.Sprintf("\t%s\t%s\t%s", readAt, readBy, readFrom)
+		}
+		if showTags {
+			line += "\t" + formatTags(result.Tags)
+		}
+		fmt.Fprintln(tw, line)
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, results []SecretResult) error {
+	if results == nil {
+		results = []SecretResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func writeCSV(w io.Writer, results []SecretResult) error {
+	cw := csv.NewWriter(w)
+	header := []string{"name", "account", "region", "last_accessed", "unused", "tags", "rotation_enabled", "rotation_rules", "last_rotated", "next_rotation", "rotation_overdue", "severity", "findings", "last_read", "last_read_by", "last_read_from", "references", "workloads"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, result := range results {
+		record := []string{
+			result.Name,
+			result.Account,
+			result.Region,
+			result.LastAccessed,
+			strconv.FormatBool(result.Unused),
+			formatTags(result.Tags),
+			strconv.FormatBool(result.RotationEnabled),
+			result.RotationRules,
+			result.LastRotated,
+			result.NextRotation,
+			strconv.FormatBool(result.RotationOverdue),
+			formatSeverity(result),
+			formatFindingRules(result.Findings),
+		}
+		readAt, readBy, readFrom := formatLastRead(result.LastRead)
+		record = append(record, readAt, readBy, readFrom, strconv.Itoa(len(result.References)), strconv.Itoa(len(result.Workloads)))
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatLastRead splits CloudTrail evidence into time, principal and source
+// cells, all empty when there is none
+func formatLastRead(read *AccessEvidence) (at, by, from string) {
+	if read == nil {
+		return "", "", ""
+	}
+	return read.Time.UTC().Format(time.RFC3339), read.Principal, read.Source
+}
+
+// formatWorkloads lists workloads by kind and name for a table cell
+func formatWorkloads(workloads []Workload) string {
+	names := make([]string, 0, len(workloads))
+	for _, w := range workloads {
+		names = append(names, w.ID())
+	}
+	return strings.Join(names, ", ")
+}
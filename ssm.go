@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// AWS SSM Parameter Store integration. Only SecureString parameters are
+// treated as secrets.
+type AWSParameterStore struct {
+	client   *ssm.Client
+	account  string
+	region   string
+	identity string
+}
+
+func NewAWSParameterStore(cfg aws.Config, account, identity string) *AWSParameterStore {
+	return &AWSParameterStore{
+		client:   ssm.NewFromConfig(cfg),
+		account:  account,
+		region:   cfg.Region,
+		identity: identity,
+	}
+}
+
+func (ps *AWSParameterStore) Location() (string, string) {
+	return ps.account, ps.region
+}
+
+// Identity is the ARN of the caller the store acts as
+func (ps *AWSParameterStore) Identity(context.Context) string {
+	return ps.identity
+}
+
+// parameterARN builds the ARN of a parameter; names of parameters in a
+// hierarchy already start with "/"
+func (ps *AWSParameterStore) parameterARN(name string) string {
+	return fmt.Sprintf("arn:aws:ssm:%s:%s:parameter/%s", ps.region, ps.account, strings.TrimPrefix(name, "/"))
+}
+
+func (ps *AWSParameterStore) Service() string {
+	return serviceParameterStore
+}
+
+// ListsDescriptions marks that DescribeParameters returns every parameter's
+// description
+func (ps *AWSParameterStore) ListsDescriptions() {}
+
+// DefaultKMSKey is the key SecureString parameters use when none is chosen
+func (ps *AWSParameterStore) DefaultKMSKey() string {
+	return "alias/aws/ssm"
+}
+
+func (ps *AWSParameterStore) ListSecretPages(ctx context.Context, fn func(page []SecretEntry) error) error {
+	input := &ssm.DescribeParametersInput{
+		ParameterFilters: []types.ParameterStringFilter{
+			{
+				Key:    aws.String("Type"),
+				Option: aws.String("Equals"),
+				Values: []string{string(types.ParameterTypeSecureString)},
+			},
+		},
+	}
+
+	paginator := ssm.NewDescribeParametersPaginator(ps.client, input)
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list parameters: %w", err)
+		}
+
+		var secrets []SecretEntry
+		for _, param := range page.Parameters {
+			if param.Name == nil || param.LastModifiedDate == nil {
+				continue
+			}
+			// Parameter Store tracks neither reads nor the original creation
+			// date, so the last modification is the best "last used" signal
+			// the metadata offers.
+			secrets = append(secrets, SecretEntry{
+				Name:             *param.Name,
+				ARN:              ps.parameterARN(*param.Name),
+				Account:          ps.account,
+				Region:           ps.region,
+				CreatedDate:      param.LastModifiedDate,
+				LastAccessedDate: param.LastModifiedDate,
+				Description:      aws.ToString(param.Description),
+				KmsKeyId:         aws.ToString(param.KeyId),
+			})
+		}
+		if err := fn(secrets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ps *AWSParameterStore) ListSecretVersions(ctx context.Context, secretName string) ([]SecretVersion, error) {
+	var versions []SecretVersion
+
+	input := &ssm.GetParameterHistoryInput{
+		Name:           aws.String(secretName),
+		WithDecryption: aws.Bool(false),
+	}
+
+	paginator := ssm.NewGetParameterHistoryPaginator(ps.client, input)
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parameter history: %w", err)
+		}
+
+		for _, h := range page.Parameters {
+			versions = append(versions, SecretVersion{
+				VersionId:   strconv.FormatInt(h.Version, 10),
+				CreatedDate: h.LastModifiedDate,
+				Stages:      h.Labels,
+			})
+		}
+	}
+
+	return versions, nil
+}
+
+func (ps *AWSParameterStore) GetSecretValue(ctx context.Context, secretName, versionId string) (SecretValue, error) {
+	input := &ssm.GetParameterInput{
+		Name:           aws.String(secretName + ":" + versionId),
+		WithDecryption: aws.Bool(true),
+	}
+
+	output, err := ps.client.GetParameter(ctx, input)
+	if err != nil {
+		return SecretValue{}, fmt.Errorf("failed to get parameter value: %w", err)
+	}
+
+	return SecretValue{String: aws.ToString(output.Parameter.Value)}, nil
+}
+
+// DeleteSecret deletes the parameter immediately; Parameter Store has no
+// recovery window, so the options are ignored.
+func (ps *AWSParameterStore) DeleteSecret(ctx context.Context, secretName string, _ DeleteOptions) error {
+	input := &ssm.DeleteParameterInput{
+		Name: aws.String(secretName),
+	}
+
+	_, err := ps.client.DeleteParameter(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to delete parameter %s: %w", secretName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SecretStore is a secrets backend that sniffy can analyze. The analyzer and
+// the TUI only talk to this interface, so any backend that can list, inspect
+// and delete secrets gets the same staleness workflow.
+type SecretStore interface {
+	// ListSecretPages lists every secret, calling fn with each page as it
+	// arrives so a scan can report progress
+	ListSecretPages(ctx context.Context, fn func(page []SecretEntry) error) error
+	ListSecretVersions(ctx context.Context, secretName string) ([]SecretVersion, error)
+	GetSecretValue(ctx context.Context, secretName, versionId string) (SecretValue, error)
+	DeleteSecret(ctx context.Context, secretName string, opts DeleteOptions) error
+}
+
+// SecretRestorer is implemented by stores that schedule deletions instead of
+// deleting immediately, and can undo them
+type SecretRestorer interface {
+	ListPendingDeletion(ctx context.Context) ([]SecretEntry, error)
+	RestoreSecret(ctx context.Context, secretName string) error
+}
+
+// recoverableStore is implemented by stores that can't restore a secret
+// themselves but whose deletes can still be undone, such as Vault's soft
+// delete. RecoverableDeletes says whether they are.
+type recoverableStore interface {
+	RecoverableDeletes() bool
+}
+
+// deletesPermanently reports whether a delete that isn't forced removes the
+// secret from the store for good
+func deletesPermanently(store SecretStore) bool {
+	if _, ok := store.(SecretRestorer); ok {
+		return false
+	}
+	if r, ok := store.(recoverableStore); ok {
+		return !r.RecoverableDeletes()
+	}
+	return true
+}
+
+// SecretWriter is implemented by stores that can create secrets and add new
+// versions to existing ones
+type SecretWriter interface {
+	CreateSecret(ctx context.Context, secretName, value string) (WrittenVersion, error)
+	// PutSecretValue adds a version and attaches stages to it; no stages
+	// means the store's default, which makes the version current
+	PutSecretValue(ctx context.Context, secretName, value string, stages []string) (WrittenVersion, error)
+}
+
+// StageUpdater is implemented by stores whose versions carry stage labels
+// that can be moved between versions
+type StageUpdater interface {
+	// UpdateVersionStage attaches stage to moveToVersionId and removes it
+	// from removeFromVersionId; either may be empty
+	UpdateVersionStage(ctx context.Context, secretName, stage, moveToVersionId, removeFromVersionId string) error
+}
+
+// WrittenVersion identifies the version a write created
+type WrittenVersion struct {
+	ARN       string
+	VersionId string
+}
+
+// DeleteOptions control how a secret is deleted
+type DeleteOptions struct {
+	// RecoveryWindowDays is how long a deleted secret can still be restored;
+	// zero means the store's default
+	RecoveryWindowDays int
+	// Force deletes the secret immediately, without a recovery window
+	Force bool
+}
+
+// SecretValue is the value of one version of a secret. Stores that can hold
+// binary secrets set Binary instead of String.
+type SecretValue struct {
+	String string
+	Binary []byte
+}
+
+// Text renders the value for display; binary values are base64 encoded
+func (v SecretValue) Text() string {
+	if v.Binary != nil {
+		return base64.StdEncoding.EncodeToString(v.Binary)
+	}
+	return v.String
+}
+
+// secretField is one key of a JSON secret
+type secretField struct {
+	Key   string
+	Value string
+}
+
+// Fields splits a JSON object secret into its keys, sorted. ok is false for
+// binary values and anything that isn't a JSON object.
+func (v SecretValue) Fields() (fields []secretField, ok bool) {
+	if v.Binary != nil {
+		return nil, false
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(v.String), &obj); err != nil || obj == nil {
+		return nil, false
+	}
+	for key, value := range obj {
+		fields = append(fields, secretField{Key: key, Value: jsonValueString(value)})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	return fields, true
+}
+
+// validateSecretValue checks a value before it is written. Values that look
+// like JSON must parse; anything else is stored as plain text.
+func validateSecretValue(value string) (isJSON bool, err error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return false, errors.New("value is empty")
+	}
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return false, nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+		return true, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return true, nil
+}
+
+// SecretVersion is a single version of a secret as reported by its store
+type SecretVersion struct {
+	VersionId        string
+	CreatedDate      *time.Time
+	LastAccessedDate *time.Time
+	Stages           []string
+}
+
+// collectPages gathers every page from a ListSecretPages function
+func collectPages(ctx context.Context, listPages func(context.Context, func([]SecretEntry) error) error) ([]SecretEntry, error) {
+	var secrets []SecretEntry
+	err := listPages(ctx, func(page []SecretEntry) error {
+		secrets = append(secrets, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+var (
+	_ SecretStore = (*AWSSecretsManager)(nil)
+	_ SecretStore = (*AWSParameterStore)(nil)
+	_ SecretStore = (*VaultKVStore)(nil)
+
+	_ SecretRestorer = (*AWSSecretsManager)(nil)
+	_ SecretWriter   = (*AWSSecretsManager)(nil)
+	_ StageUpdater   = (*AWSSecretsManager)(nil)
+	_ SecretRotator  = (*AWSSecretsManager)(nil)
+
+	_ recoverableStore = (*VaultKVStore)(nil)
+
+	_ ResourcePolicyReader = (*AWSSecretsManager)(nil)
+	_ describedStore       = (*AWSSecretsManager)(nil)
+	_ describedStore       = (*AWSParameterStore)(nil)
+	_ kmsKeyedStore        = (*AWSSecretsManager)(nil)
+	_ kmsKeyedStore        = (*AWSParameterStore)(nil)
+	_ AccessTracer         = (*AWSSecretsManager)(nil)
+	_ arnAddressedStore    = (*AWSSecretsManager)(nil)
+	_ taggedStore          = (*AWSSecretsManager)(nil)
+	_ taggedStore          = (*VaultKVStore)(nil)
+	_ servicedStore        = (*AWSSecretsManager)(nil)
+	_ servicedStore        = (*AWSParameterStore)(nil)
+)
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HashiCorp Vault KV v2 integration. The address and token are read from
+// VAULT_ADDR and VAULT_TOKEN (or ~/.vault-token) like the vault CLI does.
+type VaultKVStore struct {
+	client    *http.Client
+	address   string
+	token     string
+	namespace string
+	mount     string
+	destroy   bool
+
+	identityOnce sync.Once
+	identity     string
+}
+
+func NewVaultKVStore(mount string, destroy bool) (*VaultKVStore, error) {
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("VAULT_TOKEN is not set and ~/.vault-token is missing")
+	}
+
+	return &VaultKVStore{
+		client:    &http.Client{Timeout: 30 * time.Second},
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		mount:     strings.Trim(mount, "/"),
+		destroy:   destroy,
+	}, nil
+}
+
+type vaultVersionMetadata struct {
+	CreatedTime  string `json:"created_time"`
+	DeletionTime string `json:"deletion_time"`
+	Destroyed    bool   `json:"destroyed"`
+}
+
+type vaultMetadata struct {
+	CreatedTime    string                          `json:"created_time"`
+	UpdatedTime    string                          `json:"updated_time"`
+	CurrentVersion int                             `json:"current_version"`
+	Versions       map[string]vaultVersionMetadata `json:"versions"`
+	CustomMetadata map[string]string               `json:"custom_metadata"`
+}
+
+// errVaultNotFound is returned for 404 responses, which Vault also uses for
+// empty list results
+var errVaultNotFound = errors.New("not found")
+
+// errVaultThrottled is returned when a rate limit quota rejects a request
+var errVaultThrottled = errors.New("rate limit exceeded")
+
+func (v *VaultKVStore) do(ctx context.Context, method, kind, name string, query url.Values, body, out any) error {
+	return v.request(ctx, method, v.secretPath(kind, name), query, body, out)
+}
+
+// secretPath is the API path of a secret under the mount
+func (v *VaultKVStore) secretPath(kind, name string) string {
+	path := v.mount + "/" + kind
+	if name != "" {
+		segments := strings.Split(name, "/")
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+		path += "/" + strings.Join(segments, "/")
+	}
+	return path
+}
+
+func (v *VaultKVStore) request(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := v.address + "/v1/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errVaultNotFound
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("vault returned %s: %w", resp.Status, errVaultThrottled)
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		if len(apiErr.Errors) > 0 {
+			return fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(apiErr.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Identity is the display name of the token, looked up once
+func (v *VaultKVStore) Identity(ctx context.Context) string {
+	v.identityOnce.Do(func() {
+		var resp struct {
+			Data struct {
+				DisplayName string `json:"display_name"`
+				EntityID    string `json:"entity_id"`
+			} `json:"data"`
+		}
+		if err := v.request(ctx, http.MethodGet, "auth/token/lookup-self", nil, nil, &resp); err != nil {
+			return
+		}
+		v.identity = resp.Data.DisplayName
+		if v.identity == "" {
+			v.identity = resp.Data.EntityID
+		}
+	})
+	return v.identity
+}
+
+func (v *VaultKVStore) metadata(ctx context.Context, secretName string) (*vaultMetadata, error) {
+	var resp struct {
+		Data vaultMetadata `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "metadata", secretName, nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// ListsTags marks that each secret's custom metadata is read as its tags
+func (v *VaultKVStore) ListsTags() {}
+
+// ListSecretPages reports each folder of the mount as a page
+func (v *VaultKVStore) ListSecretPages(ctx context.Context, fn func(page []SecretEntry) error) error {
+	// Walk the metadata tree depth first; keys ending in "/" are folders
+	prefixes := []string{""}
+	for len(prefixes) > 0 {
+		prefix := prefixes[len(prefixes)-1]
+		prefixes = prefixes[:len(prefixes)-1]
+
+		var resp struct {
+			Data struct {
+				Keys []string `json:"keys"`
+			} `json:"data"`
+		}
+		err := v.do(ctx, http.MethodGet, "metadata", strings.TrimSuffix(prefix, "/"), url.Values{"list": {"true"}}, nil, &resp)
+		if err == errVaultNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list secrets under %q: %w", prefix, err)
+		}
+
+		var secrets []SecretEntry
+		for _, key := range resp.Data.Keys {
+			if strings.HasSuffix(key, "/") {
+				prefixes = append(prefixes, prefix+key)
+				continue
+			}
+
+			name := prefix + key
+			meta, err := v.metadata(ctx, name)
+			if err != nil {
+				return fmt.Errorf("failed to read metadata for %s: %w", name, err)
+			}
+
+			created := parseVaultTime(meta.CreatedTime)
+			if created == nil {
+				continue // Skip if no creation date
+			}
+			secrets = append(secrets, SecretEntry{
+				Name:             name,
+				ARN:              v.address + "/v1/" + v.secretPath("data", name),
+				CreatedDate:      created,
+				LastAccessedDate: parseVaultTime(meta.UpdatedTime),
+				Tags:             meta.CustomMetadata,
+			})
+		}
+		if err := fn(secrets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *VaultKVStore) ListSecretVersions(ctx context.Context, secretName string) ([]SecretVersion, error) {
+	meta, err := v.metadata(ctx, secretName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secret versions: %w", err)
+	}
+
+	var numbers []int
+	for key := range meta.Versions {
+		n, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	var versions []SecretVersion
+	for _, n := range numbers {
+		vm := meta.Versions[strconv.Itoa(n)]
+
+		var stages []string
+		if n == meta.CurrentVersion {
+			stages = append(stages, "current")
+		}
+		if vm.Destroyed {
+			stages = append(stages, "destroyed")
+		} else if vm.DeletionTime != "" {
+			stages = append(stages, "deleted")
+		}
+
+		versions = append(versions, SecretVersion{
+			VersionId:   strconv.Itoa(n),
+			CreatedDate: parseVaultTime(vm.CreatedTime),
+			Stages:      stages,
+		})
+	}
+
+	return versions, nil
+}
+
+func (v *VaultKVStore) GetSecretValue(ctx context.Context, secretName, versionId string) (SecretValue, error) {
+	var resp struct {
+		Data struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	err := v.do(ctx, http.MethodGet, "data", secretName, url.Values{"version": {versionId}}, nil, &resp)
+	if err == errVaultNotFound {
+		return SecretValue{}, fmt.Errorf("failed to get secret value: version %s of %s is deleted or does not exist", versionId, secretName)
+	}
+	if err != nil {
+		return SecretValue{}, fmt.Errorf("failed to get secret value: %w", err)
+	}
+
+	return SecretValue{String: string(resp.Data.Data)}, nil
+}
+
+// RecoverableDeletes is false with --vault-destroy, which removes secrets
+// permanently
+func (v *VaultKVStore) RecoverableDeletes() bool {
+	return !v.destroy
+}
+
+// DeleteSecret soft-deletes every live version of the secret, which can be
+// undone with `vault kv undelete`. With --vault-destroy or a forced delete the
+// metadata and all versions are removed permanently instead.
+func (v *VaultKVStore) DeleteSecret(ctx context.Context, secretName string, opts DeleteOptions) error {
+	if v.destroy || opts.Force {
+		if err := v.do(ctx, http.MethodDelete, "metadata", secretName, nil, nil, nil); err != nil {
+			return fmt.Errorf("failed to destroy secret %s: %w", secretName, err)
+		}
+		return nil
+	}
+
+	meta, err := v.metadata(ctx, secretName)
+	if err != nil {
+		return fmt.Errorf("failed to delete secret %s: %w", secretName, err)
+	}
+
+	var live []int
+	for key, vm := range meta.Versions {
+		n, err := strconv.Atoi(key)
+		if err != nil || vm.Destroyed || vm.DeletionTime != "" {
+			continue
+		}
+		live = append(live, n)
+	}
+	if len(live) == 0 {
+		return nil
+	}
+	sort.Ints(live)
+
+	body := map[string][]int{"versions": live}
+	if err := v.do(ctx, http.MethodPost, "delete", secretName, nil, body, nil); err != nil {
+		return fmt.Errorf("failed to delete secret %s: %w", secretName, err)
+	}
+
+	return nil
+}
+
+func parseVaultTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
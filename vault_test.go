@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakeVault serves a KV v2 mount named "secret" from metadata held in memory
+type fakeVault struct {
+	t        *testing.T
+	metadata map[string]vaultMetadata
+	values   map[string]string // "name@version" to the version's data
+
+	deleted   map[string][]int
+	destroyed []string
+}
+
+func newFakeVault(t *testing.T) *fakeVault {
+	return &fakeVault{
+		t: t,
+		metadata: map[string]vaultMetadata{
+			"top": {
+				CreatedTime:    "2026-01-01T00:00:00Z",
+				CurrentVersion: 1,
+				Versions:       map[string]vaultVersionMetadata{"1": {CreatedTime: "2026-01-01T00:00:00Z"}},
+			},
+			"app/db": {
+				CreatedTime:    "2026-01-01T00:00:00Z",
+				UpdatedTime:    "2026-03-01T00:00:00Z",
+				CurrentVersion: 3,
+				Versions: map[string]vaultVersionMetadata{
+					"1":  {CreatedTime: "2026-01-01T00:00:00Z", Destroyed: true},
+					"2":  {CreatedTime: "2026-02-01T00:00:00Z", DeletionTime: "2026-02-15T00:00:00Z"},
+					"3":  {CreatedTime: "2026-03-01T00:00:00Z"},
+					"10": {CreatedTime: "2026-03-02T00:00:00Z"},
+				},
+			},
+			"app/cache/redis": {
+				CreatedTime:    "2026-02-01T00:00:00Z",
+				CurrentVersion: 1,
+				Versions:       map[string]vaultVersionMetadata{"1": {CreatedTime: "2026-02-01T00:00:00Z"}},
+			},
+		},
+		values:  map[string]string{"app/db@3": `{"password":"hunter2"}`},
+		deleted: map[string][]int{},
+	}
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kind, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/secret/"), "/")
+
+	switch {
+	case r.Header.Get("X-Vault-Token") != "test-token":
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+
+	case kind == "metadata" && r.URL.Query().Get("list") == "true":
+		prefix := name
+		if prefix != "" {
+			prefix += "/"
+		}
+		seen := map[string]bool{}
+		var keys []string
+		for secret := range f.metadata {
+			rest, ok := strings.CutPrefix(secret, prefix)
+			if !ok {
+				continue
+			}
+			if i := strings.Index(rest, "/"); i >= 0 {
+				rest = rest[:i+1]
+			}
+			if !seen[rest] {
+				seen[rest] = true
+				keys = append(keys, rest)
+			}
+		}
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sort.Strings(keys)
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"keys": keys}})
+
+	case kind == "metadata" && r.Method == http.MethodGet:
+		meta, ok := f.metadata[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": meta})
+
+	case kind == "metadata" && r.Method == http.MethodDelete:
+		f.destroyed = append(f.destroyed, name)
+		w.WriteHeader(http.StatusNoContent)
+
+	case kind == "data":
+		value, ok := f.values[name+"@"+r.URL.Query().Get("version")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": json.RawMessage(value)}})
+
+	case kind == "delete" && r.Method == http.MethodPost:
+		var body struct {
+			Versions []int `json:"versions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Error(err)
+		}
+		f.deleted[name] = body.Versions
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		f.t.Errorf("unexpected %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestVaultStore(t *testing.T, destroy bool) (*VaultKVStore, *fakeVault) {
+	t.Helper()
+	vault := newFakeVault(t)
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+
+	t.Setenv("VAULT_ADDR", server.URL+"/")
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("VAULT_NAMESPACE", "")
+	store, err := NewVaultKVStore("/secret/", destroy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, vault
+}
+
+func TestVaultListSecrets(t *testing.T) {
+	store, _ := newTestVaultStore(t, false)
+	entries, err := collectPages(context.Background(), store.ListSecretPages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+		if entry.CreatedDate == nil {
+			t.Errorf("%s has no creation date", entry.Name)
+		}
+		if entry.Name == "app/db" && (entry.LastAccessedDate == nil || entry.LastAccessedDate.Month() != 3) {
+			t.Errorf("app/db last accessed %v, want its updated time", entry.LastAccessedDate)
+		}
+	}
+	sort.Strings(names)
+	if want := []string{"app/cache/redis", "app/db", "top"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestVaultListSecretVersions(t *testing.T) {
+	store, _ := newTestVaultStore(t, false)
+	versions, err := store.ListSecretVersions(context.Background(), "app/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string][]string{}
+	var order []string
+	for _, version := range versions {
+		got[version.VersionId] = version.Stages
+		order = append(order, version.VersionId)
+	}
+	if want := []string{"1", "2", "3", "10"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("versions = %v, want %v", order, want)
+	}
+	want := map[string][]string{
+		"1":  {"destroyed"},
+		"2":  {"deleted"},
+		"3":  {"current"},
+		"10": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stages = %v, want %v", got, want)
+	}
+}
+
+func TestVaultGetSecretValue(t *testing.T) {
+	store, _ := newTestVaultStore(t, false)
+	value, err := store.GetSecretValue(context.Background(), "app/db", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"password":"hunter2"}`; value.String != want {
+		t.Errorf("value = %s, want %s", value.String, want)
+	}
+
+	_, err = store.GetSecretValue(context.Background(), "app/db", "2")
+	if want := "failed to get secret value: version 2 of app/db is deleted or does not exist"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestVaultDeleteSecret(t *testing.T) {
+	store, vault := newTestVaultStore(t, false)
+	if err := store.DeleteSecret(context.Background(), "app/db", DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// Only versions that are neither deleted nor destroyed are soft-deleted
+	if want := []int{3, 10}; !reflect.DeepEqual(vault.deleted["app/db"], want) {
+		t.Errorf("deleted versions = %v, want %v", vault.deleted["app/db"], want)
+	}
+	if len(vault.destroyed) > 0 {
+		t.Errorf("destroyed %v, want a soft delete", vault.destroyed)
+	}
+
+	store, vault = newTestVaultStore(t, true)
+	if err := store.DeleteSecret(context.Background(), "app/db", DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"app/db"}; !reflect.DeepEqual(vault.destroyed, want) {
+		t.Errorf("destroyed = %v, want %v", vault.destroyed, want)
+	}
+
+	// A forced delete destroys the secret without --vault-destroy
+	store, vault = newTestVaultStore(t, false)
+	if err := store.DeleteSecret(context.Background(), "app/db", DeleteOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"app/db"}; !reflect.DeepEqual(vault.destroyed, want) {
+		t.Errorf("destroyed = %v, want %v", vault.destroyed, want)
+	}
+}
+
+func TestVaultError(t *testing.T) {
+	store, _ := newTestVaultStore(t, false)
+	err := store.DeleteSecret(context.Background(), "missing", DeleteOptions{})
+	if want := "failed to delete secret missing: not found"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+
+	store.token = "expired"
+	_, err = collectPages(context.Background(), store.ListSecretPages)
+	if want := `failed to list secrets under "": vault returned 403 Forbidden: permission denied`; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}